@@ -0,0 +1,164 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// API bilibili直播 HTTP 接口，用于获取连接弹幕服务器所需的信息
+type API struct {
+	client  *http.Client
+	base    string
+	cookies []*http.Cookie
+}
+
+// NewAPI client 为 nil 时使用 http.DefaultClient，base 为空时使用 APIDefaultBase
+func NewAPI(client *http.Client, base string) *API {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if base == "" {
+		base = APIDefaultBase
+	}
+	return &API{client: client, base: base}
+}
+
+// SetCookies 设置请求时携带的 cookie。登录后获取的 DanmuInfo.Token 与 UID 绑定
+func (a *API) SetCookies(cookies []*http.Cookie) {
+	a.cookies = cookies
+}
+
+// APIError 接口返回的 code 不为 0
+type APIError struct {
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("bilibili api error: code %d: %s", e.Code, e.Message)
+}
+
+// get 请求 path 并将响应中的 data 解析到 v
+func (a *API) get(ctx context.Context, path string, query url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.base+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	for _, c := range a.cookies {
+		req.AddCookie(c)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bilibili api %s: unexpected status %s", path, resp.Status)
+	}
+
+	var r struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return err
+	}
+	if r.Code != 0 {
+		return &APIError{Code: r.Code, Message: r.Message}
+	}
+	return json.Unmarshal(r.Data, v)
+}
+
+type DanmuInfo struct {
+	Group            string      `json:"group"`
+	BusinessID       int         `json:"business_id"`
+	RefreshRowFactor float64     `json:"refresh_row_factor"`
+	RefreshRate      int         `json:"refresh_rate"`
+	MaxDelay         int         `json:"max_delay"`
+	Token            string      `json:"token"` // Enter 的 key
+	HostList         []DanmuHost `json:"host_list"`
+}
+
+type DanmuHost struct {
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	WssPort int    `json:"wss_port"`
+	WsPort  int    `json:"ws_port"`
+}
+
+// WssHosts 按接口返回的顺序给出 wss 地址，可直接用于 Conn
+func (d *DanmuInfo) WssHosts() []string {
+	hosts := make([]string, 0, len(d.HostList))
+	for _, h := range d.HostList {
+		hosts = append(hosts, fmt.Sprintf("wss://%s:%d/sub", h.Host, h.WssPort))
+	}
+	return hosts
+}
+
+// WsHosts 按接口返回的顺序给出 ws 地址
+func (d *DanmuInfo) WsHosts() []string {
+	hosts := make([]string, 0, len(d.HostList))
+	for _, h := range d.HostList {
+		hosts = append(hosts, fmt.Sprintf("ws://%s:%d/sub", h.Host, h.WsPort))
+	}
+	return hosts
+}
+
+// DanmuInfo 获取房间的弹幕服务器列表与进房 token。room 为真实房间号
+func (a *API) DanmuInfo(ctx context.Context, room int64) (*DanmuInfo, error) {
+	var r = &DanmuInfo{}
+	q := url.Values{}
+	q.Set("id", strconv.FormatInt(room, 10))
+	q.Set("type", "0")
+	if err := a.get(ctx, apiDanmuInfo, q, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type RoomInfo struct {
+	RoomID         int64  `json:"room_id"`  // 真实房间号
+	ShortID        int64  `json:"short_id"` // 短号，没有时为 0
+	UID            int64  `json:"uid"`      // 主播UID
+	LiveStatus     int    `json:"live_status"`
+	LiveTime       string `json:"live_time"`
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	Tags           string `json:"tags"`
+	Attention      int    `json:"attention"`
+	Online         int    `json:"online"`
+	AreaID         int    `json:"area_id"`
+	AreaName       string `json:"area_name"`
+	ParentAreaID   int    `json:"parent_area_id"`
+	ParentAreaName string `json:"parent_area_name"`
+	UserCover      string `json:"user_cover"`
+	Keyframe       string `json:"keyframe"`
+	IsPortrait     bool   `json:"is_portrait"`
+}
+
+// IsLive 是否正在直播(不包括轮播)
+func (r *RoomInfo) IsLive() bool {
+	return r.LiveStatus == LiveStatusLive
+}
+
+// ResolveRoom 将房间号(短号或真实ID)解析为真实房间号，并返回房间基本信息
+func (a *API) ResolveRoom(ctx context.Context, id int64) (*RoomInfo, error) {
+	var r = &RoomInfo{}
+	q := url.Values{}
+	q.Set("room_id", strconv.FormatInt(id, 10))
+	if err := a.get(ctx, apiRoomInfo, q, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ResolveRoom 使用默认的 API 解析房间号
+func ResolveRoom(ctx context.Context, id int64) (*RoomInfo, error) {
+	return NewAPI(nil, "").ResolveRoom(ctx, id)
+}
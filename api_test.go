@@ -0,0 +1,66 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestAPI(t *testing.T, path string, resp string) (*httptest.Server, *API) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(resp))
+	}))
+	return s, NewAPI(s.Client(), s.URL)
+}
+
+func TestDanmuInfo(t *testing.T) {
+	s, api := newTestAPI(t, apiDanmuInfo, `{"code":0,"message":"0","ttl":1,"data":{"group":"live","business_id":0,"refresh_row_factor":0.125,"refresh_rate":100,"max_delay":5000,"token":"tok","host_list":[{"host":"a.chat.bilibili.com","port":2243,"wss_port":443,"ws_port":2244},{"host":"broadcastlv.chat.bilibili.com","port":2243,"wss_port":443,"ws_port":2244}]}}`)
+	defer s.Close()
+
+	info, err := api.DanmuInfo(context.Background(), 21852)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Token != "tok" {
+		t.Errorf("token = %q", info.Token)
+	}
+	hosts := info.WssHosts()
+	if len(hosts) != 2 || hosts[0] != "wss://a.chat.bilibili.com:443/sub" || hosts[1] != "wss://broadcastlv.chat.bilibili.com:443/sub" {
+		t.Errorf("wss hosts = %v", hosts)
+	}
+	if h := info.WsHosts(); h[0] != "ws://a.chat.bilibili.com:2244/sub" {
+		t.Errorf("ws hosts = %v", h)
+	}
+}
+
+func TestDanmuInfoAPIError(t *testing.T) {
+	s, api := newTestAPI(t, apiDanmuInfo, `{"code":-352,"message":"-352","ttl":1}`)
+	defer s.Close()
+
+	_, err := api.DanmuInfo(context.Background(), 21852)
+	var e *APIError
+	if !errors.As(err, &e) || e.Code != -352 {
+		t.Fatalf("err = %v, want APIError -352", err)
+	}
+}
+
+func TestResolveRoom(t *testing.T) {
+	s, api := newTestAPI(t, apiRoomInfo, `{"code":0,"msg":"ok","message":"ok","data":{"uid":9617619,"room_id":22637261,"short_id":6,"attention":100,"online":0,"is_portrait":false,"description":"","live_status":1,"area_id":86,"parent_area_id":2,"parent_area_name":"网游","old_area_id":1,"background":"","title":"哔哩哔哩英雄联盟赛事","user_cover":"","keyframe":"","is_strict_room":false,"live_time":"2022-05-01 16:00:00","tags":"","is_anchor":0,"room_silent_type":"","room_silent_level":0,"room_silent_second":0,"area_name":"英雄联盟"}}`)
+	defer s.Close()
+
+	r, err := api.ResolveRoom(context.Background(), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.RoomID != 22637261 || r.ShortID != 6 || r.UID != 9617619 || !r.IsLive() || r.Title != "哔哩哔哩英雄联盟赛事" {
+		t.Errorf("unexpected room info: %+v", r)
+	}
+}
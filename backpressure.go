@@ -0,0 +1,280 @@
+package live
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// BackpressurePolicy Rev 来不及读取时的处理方式。
+// 消息在接收协程中投递，BackpressureDropNewest 等待与 BackpressureBlock 期间不会读取 websocket
+type BackpressurePolicy int
+
+const (
+	BackpressureDropNewest BackpressurePolicy = iota // 等待 Timeout 后丢弃新消息(默认)，超时后的 Timeout 内 Rev 没有空位时直接丢弃
+	BackpressureBlock                                // 一直等待，期间暂停读取 websocket，包括心跳回应
+	BackpressureDropOldest                           // 消息进入环形缓冲，满时丢弃最旧的消息
+	BackpressureSpill                                // 消息进入内存缓冲，满时写入磁盘，之后按顺序读回
+)
+
+// Backpressure 背压设置
+type Backpressure struct {
+	Policy   BackpressurePolicy
+	Timeout  time.Duration // BackpressureDropNewest 的等待时间，<=0 时为 5s
+	Buffer   int           // BackpressureDropOldest 与 BackpressureSpill 的内存缓冲大小，<=0 时为 1024
+	SpillDir string        // BackpressureSpill 临时文件所在目录，为空时使用 os.TempDir
+}
+
+// Stats 消息投递统计
+type Stats struct {
+	Delivered uint64 // 已投递到 Rev 的消息数
+	Dropped   uint64 // 因 Rev 来不及读取而丢弃的消息数
+	Spilled   uint64 // 写入过磁盘的消息数
+	Pending   int    // 缓冲中等待投递的消息数
+}
+
+// SetBackpressure 设置 Rev 的背压策略，需在 Enter 前调用
+func (l *Live) SetBackpressure(b Backpressure) error {
+	if b.Timeout <= 0 {
+		b.Timeout = 5 * time.Second
+	}
+	if b.Buffer <= 0 {
+		b.Buffer = 1024
+	}
+	switch b.Policy {
+	case BackpressureDropNewest, BackpressureBlock:
+		l.queue = nil
+	case BackpressureDropOldest, BackpressureSpill:
+		l.queue = newQueue(l, b)
+	default:
+		return fmt.Errorf("unsupported backpressure policy: %d", b.Policy)
+	}
+	l.bp = b
+	return nil
+}
+
+// Stats 返回消息投递统计，可用于监控消费是否跟得上
+func (l *Live) Stats() Stats {
+	l.statsMu.Lock()
+	st := l.stats
+	l.statsMu.Unlock()
+	if l.queue != nil {
+		st.Pending = l.queue.len()
+	}
+	return st
+}
+func (l *Live) count(f func(st *Stats)) {
+	l.statsMu.Lock()
+	f(&l.stats)
+	l.statsMu.Unlock()
+}
+
+// queue 位于 push 与 Rev 之间的缓冲，由 forward 协程按顺序转发到 Rev
+type queue struct {
+	live   *Live
+	policy BackpressurePolicy
+	dir    string
+
+	mu     sync.Mutex
+	ring   []*Transport
+	head   int // 最旧消息的下标
+	n      int // 内存中的消息数
+	notify chan struct{}
+
+	// 磁盘部分，disk>0 时新消息都写入磁盘以保证顺序
+	disk int
+	file *os.File
+	enc  *json.Encoder
+	rf   *os.File // 读句柄
+	dec  *json.Decoder
+}
+
+// spillRecord 写入磁盘的消息
+type spillRecord struct {
+	Cmd     string `json:"cmd,omitempty"`
+	Raw     []byte `json:"raw,omitempty"`
+	Err     string `json:"err,omitempty"`
+	Attempt int    `json:"attempt,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Host    string `json:"host,omitempty"`
+}
+
+func newQueue(l *Live, b Backpressure) *queue {
+	return &queue{
+		live:   l,
+		policy: b.Policy,
+		dir:    b.SpillDir,
+		ring:   make([]*Transport, b.Buffer),
+		notify: make(chan struct{}, 1),
+	}
+}
+
+func (q *queue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n + q.disk
+}
+
+// put 不会阻塞
+func (q *queue) put(t *Transport) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer q.wake()
+
+	if q.disk == 0 && q.n < len(q.ring) {
+		q.ring[(q.head+q.n)%len(q.ring)] = t
+		q.n++
+		return
+	}
+	if q.policy == BackpressureDropOldest {
+		q.ring[q.head] = t
+		q.head = (q.head + 1) % len(q.ring)
+		q.live.count(func(st *Stats) { st.Dropped++ })
+		return
+	}
+	if err := q.spill(t); err != nil {
+		q.live.error("failed to spill msg", "err", err)
+		q.live.count(func(st *Stats) { st.Dropped++ })
+		return
+	}
+	q.disk++
+	q.live.count(func(st *Stats) { st.Spilled++ })
+}
+func (q *queue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop 取出最旧的消息，没有消息时返回 nil
+func (q *queue) pop() *Transport {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.n > 0 {
+		t := q.ring[q.head]
+		q.ring[q.head] = nil
+		q.head = (q.head + 1) % len(q.ring)
+		q.n--
+		return t
+	}
+	if q.disk > 0 {
+		t, err := q.unspill()
+		if err != nil {
+			q.live.error("failed to read spilled msgs", "err", err)
+			q.live.count(func(st *Stats) { st.Dropped += uint64(q.disk) })
+			q.reset()
+			return nil
+		}
+		if q.disk--; q.disk == 0 {
+			q.reset()
+		}
+		return t
+	}
+	return nil
+}
+
+// forward 将缓冲中的消息按顺序转发到 Rev，直到 ctx 结束
+func (q *queue) forward(ctx context.Context) {
+	for {
+		t := q.pop()
+		if t == nil {
+			select {
+			case <-q.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case q.live.Rev <- t:
+			q.live.count(func(st *Stats) { st.Delivered++ })
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *queue) spill(t *Transport) error {
+	if q.file == nil {
+		f, err := os.CreateTemp(q.dir, "biligo-live-spill-*")
+		if err != nil {
+			return err
+		}
+		q.file = f
+		q.enc = json.NewEncoder(f)
+		q.dec = nil
+	}
+	r := spillRecord{}
+	if t.Error != nil {
+		r.Err = t.Error.Error()
+	}
+	if t.Msg != nil {
+		r.Cmd = t.Msg.Cmd()
+		r.Raw = t.Msg.Raw()
+		if rc, ok := t.Msg.(*MsgReconnect); ok {
+			r.Attempt = rc.Attempt
+			r.Host = rc.Host
+			if rc.Reason != nil {
+				r.Reason = rc.Reason.Error()
+			}
+		}
+	}
+	return q.enc.Encode(&r)
+}
+func (q *queue) unspill() (*Transport, error) {
+	if q.dec == nil {
+		rf, err := os.Open(q.file.Name())
+		if err != nil {
+			return nil, err
+		}
+		q.rf = rf
+		q.dec = json.NewDecoder(bufio.NewReader(rf))
+	}
+	var r spillRecord
+	if err := q.dec.Decode(&r); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	t := &Transport{}
+	if r.Err != "" {
+		t.Error = errors.New(r.Err)
+	}
+	b := base{raw: r.Raw}
+	switch r.Cmd {
+	case "":
+	case cmdHeartbeatReply:
+		t.Msg = &MsgHeartbeatReply{base: b}
+	case cmdReconnect:
+		rc := &MsgReconnect{base: b, Attempt: r.Attempt, Host: r.Host}
+		if r.Reason != "" {
+			rc.Reason = errors.New(r.Reason)
+		}
+		t.Msg = rc
+	default:
+		t.Msg = q.live.switchCmd(r.Cmd, r.Raw)
+	}
+	return t, nil
+}
+
+// reset 删除磁盘文件
+func (q *queue) reset() {
+	if q.rf != nil {
+		_ = q.rf.Close()
+	}
+	if q.file != nil {
+		_ = q.file.Close()
+		_ = os.Remove(q.file.Name())
+	}
+	q.file, q.enc, q.rf, q.dec, q.disk = nil, nil, nil, nil, 0
+}
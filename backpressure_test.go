@@ -0,0 +1,168 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func testMsg(i int) Msg {
+	return &MsgGeneral{base: base{raw: []byte(fmt.Sprintf(`{"cmd":"C%d"}`, i))}}
+}
+
+func TestBackpressureDropNewest(t *testing.T) {
+	l := NewLive(false, time.Second, 1, nil)
+	if err := l.SetBackpressure(Backpressure{Policy: BackpressureDropNewest, Timeout: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		l.push(context.Background(), testMsg(i), nil)
+	}
+	if st := l.Stats(); st.Delivered != 1 || st.Dropped != 2 {
+		t.Errorf("stats = %+v", st)
+	}
+	if tp := <-l.Rev; tp.Msg.Cmd() != "C0" {
+		t.Errorf("got %s, want C0", tp.Msg.Cmd())
+	}
+}
+
+func TestBackpressureDropNewestStalled(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	l := NewLive(false, time.Second, 1, nil)
+	if err := l.SetBackpressure(Backpressure{Policy: BackpressureDropNewest, Timeout: timeout}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	l.push(ctx, testMsg(0), nil)
+	l.push(ctx, testMsg(1), nil)
+
+	// Rev 已经等待超时，之后的消息直接丢弃
+	start := time.Now()
+	for i := 2; i < 10; i++ {
+		l.push(ctx, testMsg(i), nil)
+	}
+	if d := time.Since(start); d >= timeout {
+		t.Errorf("push blocked for %s after Rev stalled", d)
+	}
+	if st := l.Stats(); st.Delivered != 1 || st.Dropped != 9 {
+		t.Errorf("stats = %+v", st)
+	}
+
+	<-l.Rev
+	l.push(ctx, testMsg(10), nil)
+	if tp := <-l.Rev; tp.Msg.Cmd() != "C10" {
+		t.Errorf("got %s, want C10", tp.Msg.Cmd())
+	}
+	if !l.stalled.IsZero() {
+		t.Error("stalled not cleared after Rev drained")
+	}
+}
+
+func TestBackpressureDropNewestRecover(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	// Rev 无缓存，消费者没有阻塞在 Rev 上时非阻塞发送总会失败
+	l := NewLive(false, time.Second, 0, nil)
+	if err := l.SetBackpressure(Backpressure{Policy: BackpressureDropNewest, Timeout: timeout}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	l.push(ctx, testMsg(0), nil)
+
+	// 慢消费者恢复后，冷却期过去的消息应重新等待 Rev 并投递成功
+	got := make(chan string, 5)
+	go func() {
+		for i := 0; i < 5; i++ {
+			time.Sleep(time.Millisecond)
+			got <- (<-l.Rev).Msg.Cmd()
+		}
+	}()
+	time.Sleep(2 * timeout)
+	for i := 1; i <= 5; i++ {
+		l.push(ctx, testMsg(i), nil)
+	}
+	for i := 1; i <= 5; i++ {
+		select {
+		case cmd := <-got:
+			if cmd != fmt.Sprintf("C%d", i) {
+				t.Errorf("got %s, want C%d", cmd, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("C%d not delivered, stats = %+v", i, l.Stats())
+		}
+	}
+	if st := l.Stats(); st.Delivered != 5 || st.Dropped != 1 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestBackpressureDropOldest(t *testing.T) {
+	l := NewLive(false, time.Second, 0, nil)
+	if err := l.SetBackpressure(Backpressure{Policy: BackpressureDropOldest, Buffer: 2}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		l.push(context.Background(), testMsg(i), nil)
+	}
+	if st := l.Stats(); st.Dropped != 3 || st.Pending != 2 {
+		t.Errorf("stats = %+v", st)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.queue.forward(ctx)
+	for _, want := range []string{"C3", "C4"} {
+		if tp := <-l.Rev; tp.Msg.Cmd() != want {
+			t.Errorf("got %s, want %s", tp.Msg.Cmd(), want)
+		}
+	}
+}
+
+func TestBackpressureSpill(t *testing.T) {
+	dir := t.TempDir()
+	l := NewLive(false, time.Second, 0, nil)
+	if err := l.SetBackpressure(Backpressure{Policy: BackpressureSpill, Buffer: 2, SpillDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		l.push(ctx, testMsg(i), nil)
+	}
+	l.push(ctx, &MsgDanmaku{base: base{raw: []byte(`{"cmd":"DANMU_MSG"}`)}}, nil)
+	l.push(ctx, &MsgHeartbeatReply{base: base{raw: []byte{0, 0, 0, 1}}}, nil)
+	l.push(ctx, &MsgReconnect{Attempt: 2, Reason: errors.New("eof"), Host: "h"}, nil)
+	l.push(ctx, nil, errors.New("boom"))
+	if st := l.Stats(); st.Spilled != 7 || st.Pending != 9 || st.Dropped != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go l.queue.forward(ctx)
+	for i := 0; i < 5; i++ {
+		if tp := <-l.Rev; tp.Msg.Cmd() != fmt.Sprintf("C%d", i) {
+			t.Errorf("got %s, want C%d", tp.Msg.Cmd(), i)
+		}
+	}
+	if _, ok := (<-l.Rev).Msg.(*MsgDanmaku); !ok {
+		t.Error("want *MsgDanmaku")
+	}
+	if hb, ok := (<-l.Rev).Msg.(*MsgHeartbeatReply); !ok || hb.GetHot() != 1 {
+		t.Error("want *MsgHeartbeatReply with hot 1")
+	}
+	if rc, ok := (<-l.Rev).Msg.(*MsgReconnect); !ok || rc.Attempt != 2 || rc.Host != "h" || rc.Reason.Error() != "eof" {
+		t.Errorf("unexpected reconnect msg: %+v", rc)
+	}
+	if tp := <-l.Rev; tp.Error == nil || tp.Error.Error() != "boom" {
+		t.Errorf("unexpected error: %v", tp.Error)
+	}
+
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("spill file not removed: %v", files)
+	}
+	if st := l.Stats(); st.Pending != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/iyear/biligo-live"
 	"github.com/urfave/cli/v2"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
@@ -31,7 +32,7 @@ func createCli() *cli.App {
 			&cli.Int64Flag{
 				Name:  "room",
 				Value: 0,
-				Usage: "room ID",
+				Usage: "room ID, short ID is resolved automatically",
 			},
 			&cli.Int64Flag{
 				Name:  "uid",
@@ -41,7 +42,27 @@ func createCli() *cli.App {
 			&cli.StringFlag{
 				Name:  "user-key",
 				Value: "",
-				Usage: "user mark",
+				Usage: "user mark, fetched from getDanmuInfo when empty",
+			},
+			&cli.StringFlag{
+				Name:  "cookie",
+				Value: "",
+				Usage: "cookie header such as \"SESSDATA=...; DedeUserID=...; buvid3=...\", used for logged-in entry",
+			},
+			&cli.StringFlag{
+				Name:  "cookie-file",
+				Value: "",
+				Usage: "netscape cookie file, used for logged-in entry",
+			},
+			&cli.IntFlag{
+				Name:  "protover",
+				Value: live.ProtoverZlib,
+				Usage: "protocol version: 0(plain), 2(zlib), 3(brotli)",
+			},
+			&cli.BoolFlag{
+				Name:  "reconnect",
+				Value: false,
+				Usage: "reconnect automatically when disconnected",
 			},
 			&cli.BoolFlag{
 				Name:  "debug",
@@ -59,22 +80,88 @@ func run(c *cli.Context) error {
 	uid := c.Int64("uid")
 
 	// 获取一个Live实例
-	// debug: debug模式，输出一些额外的信息
-	// heartbeat: 心跳包发送间隔。不发送心跳包，70 秒之后会断开连接，通常每 30 秒发送 1 次
-	// cache: Rev channel 的缓存
-	// recover: panic recover后的操作函数
-	l := live.NewLive(c.Bool("debug"), 30*time.Second, 0, func(err error) {
-		log.Println("panic:", err)
-		// do something...
-	})
+	// WithDebug: debug模式，输出一些额外的信息
+	// WithHeartbeat: 心跳包发送间隔。不发送心跳包，70 秒之后会断开连接，通常每 30 秒发送 1 次
+	// WithRecover: panic recover后的操作函数
+	// WithProtover: 协议版本，brotli 压缩率更高
+	liveOpts := []live.Option{
+		live.WithDebug(c.Bool("debug")),
+		live.WithHeartbeat(30 * time.Second),
+		live.WithRecover(func(err error) {
+			log.Println("panic:", err)
+			// do something...
+		}),
+		live.WithProtover(c.Int("protover")),
+	}
+	// 断线后自动重连，重连成功会收到 MsgReconnect
+	if c.Bool("reconnect") {
+		liveOpts = append(liveOpts, live.WithReconnect(live.DefaultReconnectPolicy()))
+	}
+	l, err := live.New(liveOpts...)
+	if err != nil {
+		return err
+	}
+
+	// 短号转换为真实房间号
+	if r, err := live.ResolveRoom(context.Background(), room); err != nil {
+		log.Println("failed to resolve room:", err)
+	} else {
+		room = r.RoomID
+	}
 
-	// 连接ws服务器
+	// 登录凭据，匿名进房时弹幕用户名会被打码
+	var cookies []*http.Cookie
+	switch {
+	case c.IsSet("cookie") && c.IsSet("cookie-file"):
+		return fmt.Errorf("--cookie and --cookie-file cannot be used together")
+	case c.IsSet("cookie"):
+		cookies = live.ParseCookieHeader(c.String("cookie"))
+	case c.IsSet("cookie-file"):
+		if cookies, err = live.LoadCookieFile(c.String("cookie-file")); err != nil {
+			return err
+		}
+	}
+	opts, err := live.EnterOptionsFromCookies(cookies)
+	if err != nil {
+		return err
+	}
+	// 登录时 UID 必须与 cookie 一致
+	if opts.UID == 0 {
+		opts.UID = uid
+	} else if c.IsSet("uid") && uid != opts.UID {
+		return fmt.Errorf("--uid %d does not match DedeUserID %d in the cookie", uid, opts.UID)
+	}
+	api := live.NewAPI(nil, "")
+	api.SetCookies(cookies)
+
+	// 通过 getDanmuInfo 获取弹幕服务器地址与进房 token，失败时使用默认地址
+	hosts := []string{live.WsDefaultHost}
+	if info, err := api.DanmuInfo(context.Background(), room); err != nil {
+		log.Println("failed to get danmu info:", err)
+	} else {
+		hosts = append(info.WssHosts(), hosts...)
+		if user_key == "" {
+			user_key = info.Token
+		}
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", live.UserAgent)
+	header.Set("Origin", "https://live.bilibili.com")
+	if len(cookies) > 0 {
+		header.Set("Cookie", live.CookieHeader(cookies))
+	}
+
+	// 连接ws服务器，依次尝试各个host
 	// dialer: ws dialer
-	// host: bilibili live ws host
-	if err := l.Conn(websocket.DefaultDialer, live.WsDefaultHost); err != nil {
+	// hosts: bilibili live ws hosts
+	// header: 握手时发送的请求头
+	// timeout: 单个host的连接超时
+	if err := l.ConnHostsContext(c.Context, websocket.DefaultDialer, hosts, header, 10*time.Second); err != nil {
 		log.Fatal(err)
 		return err
 	}
+	log.Println("connected to", l.Host())
 
 	ctx, stop := context.WithCancel(context.Background())
 	var wg sync.WaitGroup
@@ -84,10 +171,12 @@ func run(c *cli.Context) error {
 	go func() {
 		defer wg.Done()
 		// 进入房间
-		// room: room id(真实ID，短号需自行转换)
-		// key: 用户标识，可留空
-		// uid: 用户UID，可随机生成
-		if err := l.Enter(ctx, room, user_key, uid); err != nil {
+		// room: room id(真实ID，短号可通过 live.ResolveRoom 转换)
+		// opts.Key: 用户标识，可留空
+		// opts.UID: 用户UID，可随机生成
+		// opts.Buvid: 设备标识，登录进房时需要
+		opts.Key = user_key
+		if err := l.EnterWithOptions(ctx, room, opts); err != nil {
 			log.Println("Error Encountered: ", err)
 			log.Println("Room Disconnected")
 			ifError <- err
@@ -111,70 +200,48 @@ func run(c *cli.Context) error {
 	select {
 	case <-sc:
 		fmt.Println("I want to stop")
-		// 关闭ws连接与相关协程
-		stop()
 		break
 	case err := <-ifError:
 		fmt.Println("I don't want to stop, but I encountered an error: ", err)
 		break
 	}
+	// 关闭ws连接与相关协程
+	stop()
 
 	wg.Wait()
 	return nil
 }
 
 func rev(ctx context.Context, l *live.Live) {
-	for {
-		select {
-		case tp := <-l.Rev:
-			if tp.Error != nil {
-				// do something...
-				log.Println(tp.Error)
-				continue
-			}
-			handle(tp.Msg)
-		case <-ctx.Done():
-			log.Println("rev func stopped")
-			return
-		}
-	}
-}
-func handle(msg live.Msg) {
-	// 使用 msg.(type) 进行事件跳转和处理，常见事件基本都完成了解析(Parse)功能，不常见的功能有一些实在太难抓取
-	// 更多注释和说明等待添加
-	switch msg.(type) {
+	// 使用 Dispatcher 按 cmd 注册处理函数，解析失败和处理函数的 panic 统一交给 OnError
+	d := live.NewDispatcher()
+	d.OnError(func(err error) {
+		// do something...
+		log.Println(err)
+	})
 	// 心跳回应直播间人气值
-	case *live.MsgHeartbeatReply:
-		fmt.Printf("HOT: %d\n", msg.(*live.MsgHeartbeatReply).GetHot())
+	d.OnHeartbeat(func(hot int) {
+		fmt.Printf("HOT: %d\n", hot)
+	})
+	// 断线重连成功，期间可能丢失了消息
+	d.OnReconnect(func(rc *live.MsgReconnect) {
+		fmt.Printf("reconnected to %s after %d attempt(s): %v\n", rc.Host, rc.Attempt, rc.Reason)
+	})
 	// 弹幕消息
-	case *live.MsgDanmaku:
-		dm, err := msg.(*live.MsgDanmaku).Parse()
-		if err != nil {
-			log.Println(err)
-			return
-		}
+	d.OnDanmaku(func(dm *live.Danmaku) {
 		fmt.Printf("弹幕[%v]: (%s[%s]) %s\n", time.UnixMilli(dm.Time).Format("2006-01-02T15:04:05"), dm.Uname, dm.MedalName, dm.Content)
+	})
 	// 礼物消息
-	case *live.MsgSendGift:
-		g, err := msg.(*live.MsgSendGift).Parse()
-		if err != nil {
-			log.Println(err)
-			return
-		}
+	d.OnGift(func(g *live.SendGift) {
 		fmt.Printf("%s[%v]: %s %d个%s\n", g.Action, time.Unix(g.Timestamp, 0).Format("2006-01-02T15:04:05"), g.Uname, g.Num, g.GiftName)
+	})
 	// 直播间粉丝数变化消息
-	case *live.MsgFansUpdate:
-		f, err := msg.(*live.MsgFansUpdate).Parse()
-		if err != nil {
-			log.Println(err)
-			return
-		}
+	d.OnFansUpdate(func(f *live.FansUpdate) {
 		fmt.Printf("Room: %d,fans: %d,fansClub: %d\n", f.RoomID, f.Fans, f.FansClub)
-	// case:......
+	})
+	// live未实现的CMD命令可以通过 OnRaw 处理raw数据。也可以提issue更新这个CMD
+	// d.OnRaw("SOME_CMD", func(raw []byte) {})
 
-	// General 表示live未实现的CMD命令，请自行处理raw数据。也可以提issue更新这个CMD
-	case *live.MsgGeneral:
-		//fmt.Println("unknown msg type|raw:", string(msg.Raw()))
-		//fmt.Println()
-	}
+	d.Run(ctx, l.Rev)
+	log.Println("rev func stopped")
 }
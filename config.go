@@ -32,6 +32,13 @@ const (
 	wsVerBrotli = 3
 )
 
+// 进房时协商的协议版本，决定服务器下发消息的压缩方式
+const (
+	ProtoverPlain  = wsVerPlain  // 不压缩
+	ProtoverZlib   = wsVerZlib   // zlib 压缩(默认)
+	ProtoverBrotli = wsVerBrotli // brotli 压缩，带宽更低
+)
+
 // cmd
 const (
 	cmdAttention                 = "ATTENTION"                     // 用户关注
@@ -116,6 +123,28 @@ const (
 	cmdWatChedChange             = "WATCHED_CHANGE"                // 直播间看过人数变化
 )
 
+// 非服务器下发的 cmd
+const (
+	cmdHeartbeatReply = "HEARTBEAT_REPLY" // 心跳回应
+	cmdReconnect      = "RECONNECT"       // 断线重连成功
+)
+
+const (
+	WsDefaultHost  = "wss://broadcastlv.chat.bilibili.com/sub"
+	APIDefaultBase = "https://api.live.bilibili.com"
+)
+
+// live status
+const (
+	LiveStatusPreparing = 0 // 未开播
+	LiveStatusLive      = 1 // 直播中
+	LiveStatusRound     = 2 // 轮播中
+)
+
+// api
 const (
-	WsDefaultHost = "wss://broadcastlv.chat.bilibili.com/sub"
+	apiDanmuInfo = "/xlive/web-room/v1/index/getDanmuInfo"
+	apiRoomInfo  = "/room/v1/Room/get_info"
+	// UserAgent 请求 API 时使用的 User-Agent，也可用于握手的请求头
+	UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/100.0.4896.127 Safari/537.36"
 )
@@ -0,0 +1,82 @@
+package live
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadCookieFile 读取 Netscape 格式的 cookie 文件(如浏览器插件导出的 cookies.txt)
+func LoadCookieFile(path string) ([]*http.Cookie, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cookies []*http.Cookie
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		if httpOnly {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// domain include_subdomains path secure expires name value
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("cookie file %s line %d: want 7 fields, got %d", path, n, len(fields))
+		}
+		cookies = append(cookies, &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		})
+	}
+	if err = sc.Err(); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// CookieHeader 将 cookies 拼接为 Cookie 请求头，可用于 ConnContext 的 header
+func CookieHeader(cookies []*http.Cookie) string {
+	s := make([]string, 0, len(cookies))
+	for _, c := range cookies {
+		s = append(s, c.Name+"="+c.Value)
+	}
+	return strings.Join(s, "; ")
+}
+
+// ParseCookieHeader 解析 Cookie 请求头形式的字符串，如浏览器中复制的 "SESSDATA=xxx; buvid3=xxx"
+func ParseCookieHeader(s string) []*http.Cookie {
+	return (&http.Request{Header: http.Header{"Cookie": {s}}}).Cookies()
+}
+
+// EnterOptionsFromCookies 从登录 cookie 中取出 UID(DedeUserID，与 SESSDATA 同时下发)和 buvid3。
+// 服务器只在握手携带了同一份 cookie 时认可该 UID，Key 需要使用同一份 cookie 请求 DanmuInfo 获取
+func EnterOptionsFromCookies(cookies []*http.Cookie) (*EnterOptions, error) {
+	opts := &EnterOptions{}
+	for _, c := range cookies {
+		switch c.Name {
+		case "DedeUserID":
+			uid, err := strconv.ParseInt(c.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DedeUserID %q: %s", c.Value, err)
+			}
+			opts.UID = uid
+		case "buvid3":
+			opts.Buvid = c.Value
+		}
+	}
+	return opts, nil
+}
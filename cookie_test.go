@@ -0,0 +1,65 @@
+package live
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCookieFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "cookies.txt")
+	content := "# Netscape HTTP Cookie File\n" +
+		"\n" +
+		".bilibili.com\tTRUE\t/\tFALSE\t1700000000\tbuvid3\tABCD-1234infoc\n" +
+		"#HttpOnly_.bilibili.com\tTRUE\t/\tTRUE\t1700000000\tSESSDATA\tsess%2Cdata\n" +
+		".bilibili.com\tTRUE\t/\tFALSE\t1700000000\tDedeUserID\t2920960\n"
+	if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, err := LoadCookieFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 3 {
+		t.Fatalf("got %d cookies, want 3", len(cookies))
+	}
+	if c := cookies[1]; c.Name != "SESSDATA" || !c.HttpOnly || !c.Secure {
+		t.Errorf("unexpected SESSDATA cookie: %+v", c)
+	}
+	if h := CookieHeader(cookies); h != "buvid3=ABCD-1234infoc; SESSDATA=sess%2Cdata; DedeUserID=2920960" {
+		t.Errorf("CookieHeader = %q", h)
+	}
+
+	opts, err := EnterOptionsFromCookies(cookies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.UID != 2920960 || opts.Buvid != "ABCD-1234infoc" {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+}
+
+func TestLoadCookieFileMalformed(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := os.WriteFile(p, []byte(".bilibili.com\tTRUE\t/\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCookieFile(p); err == nil {
+		t.Error("want error for malformed line")
+	}
+}
+
+func TestParseCookieHeader(t *testing.T) {
+	cookies := ParseCookieHeader("SESSDATA=sess%2Cdata; DedeUserID=2920960; buvid3=ABCD-1234infoc")
+	if got := CookieHeader(cookies); got != "SESSDATA=sess%2Cdata; DedeUserID=2920960; buvid3=ABCD-1234infoc" {
+		t.Errorf("CookieHeader = %q", got)
+	}
+	opts, err := EnterOptionsFromCookies(cookies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.UID != 2920960 || opts.Buvid != "ABCD-1234infoc" {
+		t.Errorf("opts = %+v", opts)
+	}
+}
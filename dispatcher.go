@@ -0,0 +1,225 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Dispatcher 按 cmd 将 Rev 中的消息分发给注册的处理函数，替代手写的 switch msg.(type)。
+// 每个处理函数的 panic 会被单独捕获，解析失败、panic 与 Transport 中的错误都交给 OnError。
+// 同一条消息只解析一次，OnGift 等处理函数收到的是同一个解析结果，不应修改；解析失败也只报告一次
+type Dispatcher struct {
+	mu       sync.RWMutex
+	handlers map[string][]handler
+	all      []handler
+	onError  func(error)
+}
+
+type handler func(m Msg, p *parsed) error
+
+// parsed 一条消息的解析结果
+type parsed struct {
+	done bool
+	v    interface{}
+	err  error
+}
+
+// DispatchError 分发时产生的错误
+type DispatchError struct {
+	Cmd   string
+	Err   error
+	Panic bool // 处理函数发生了 panic
+}
+
+func (e *DispatchError) Error() string {
+	if e.Panic {
+		return fmt.Sprintf("handler of %s panicked: %s", e.Cmd, e.Err)
+	}
+	return fmt.Sprintf("failed to handle %s: %s", e.Cmd, e.Err)
+}
+func (e *DispatchError) Unwrap() error {
+	return e.Err
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{handlers: make(map[string][]handler)}
+}
+
+// Run 从 rev 读取并分发消息，直到 ctx 结束或 rev 被关闭
+func (d *Dispatcher) Run(ctx context.Context, rev <-chan *Transport) {
+	for {
+		select {
+		case t, ok := <-rev:
+			if !ok {
+				return
+			}
+			d.Dispatch(t)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Dispatch 分发一条 Transport，可用于自行读取 Rev 的场景
+func (d *Dispatcher) Dispatch(t *Transport) {
+	if t == nil {
+		return
+	}
+	if t.Error != nil {
+		d.error(t.Error)
+	}
+	if t.Msg != nil {
+		d.DispatchMsg(t.Msg)
+	}
+}
+
+// DispatchMsg 将消息依次交给该 cmd 的处理函数与 OnMsg 注册的处理函数
+func (d *Dispatcher) DispatchMsg(m Msg) {
+	cmd := m.Cmd()
+	d.mu.RLock()
+	hs := append(append([]handler(nil), d.handlers[cmd]...), d.all...)
+	d.mu.RUnlock()
+	p := &parsed{}
+	for _, h := range hs {
+		d.call(cmd, h, m, p)
+	}
+}
+
+func (d *Dispatcher) call(cmd string, h handler, m Msg, p *parsed) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+			d.error(&DispatchError{Cmd: cmd, Err: err, Panic: true})
+		}
+	}()
+	if err := h(m, p); err != nil {
+		d.error(&DispatchError{Cmd: cmd, Err: err})
+	}
+}
+
+func (d *Dispatcher) error(err error) {
+	d.mu.RLock()
+	f := d.onError
+	d.mu.RUnlock()
+	if f != nil {
+		f(err)
+	}
+}
+
+func (d *Dispatcher) on(cmd string, h func(Msg) error) {
+	d.add(cmd, func(m Msg, _ *parsed) error { return h(m) })
+}
+
+// onParsed 注册需要解析的处理函数，parse 在每条消息上最多调用一次，失败时只返回一次错误
+func (d *Dispatcher) onParsed(cmd string, parse func(raw []byte) (interface{}, error), f func(interface{})) {
+	d.add(cmd, func(m Msg, p *parsed) error {
+		if !p.done {
+			p.done = true
+			if p.v, p.err = parse(m.Raw()); p.err != nil {
+				return p.err
+			}
+		}
+		if p.err != nil {
+			return nil
+		}
+		f(p.v)
+		return nil
+	})
+}
+
+func (d *Dispatcher) add(cmd string, h handler) {
+	d.mu.Lock()
+	d.handlers[cmd] = append(d.handlers[cmd], h)
+	d.mu.Unlock()
+}
+
+// OnError 解析失败、处理函数 panic 以及 Transport 中的错误
+func (d *Dispatcher) OnError(f func(error)) {
+	d.mu.Lock()
+	d.onError = f
+	d.mu.Unlock()
+}
+
+// OnMsg 所有消息
+func (d *Dispatcher) OnMsg(f func(Msg)) {
+	d.mu.Lock()
+	d.all = append(d.all, func(m Msg, _ *parsed) error {
+		f(m)
+		return nil
+	})
+	d.mu.Unlock()
+}
+
+// OnRaw 指定 cmd 的原始数据，包括 live 未实现的 cmd
+func (d *Dispatcher) OnRaw(cmd string, f func([]byte)) {
+	d.on(cmd, func(m Msg) error {
+		f(m.Raw())
+		return nil
+	})
+}
+
+// OnHeartbeat 心跳回应，hot 为直播间人气值
+func (d *Dispatcher) OnHeartbeat(f func(hot int)) {
+	d.on(cmdHeartbeatReply, func(m Msg) error {
+		f((&MsgHeartbeatReply{base: base{raw: m.Raw()}}).GetHot())
+		return nil
+	})
+}
+
+// OnReconnect 断线重连成功
+func (d *Dispatcher) OnReconnect(f func(*MsgReconnect)) {
+	d.on(cmdReconnect, func(m Msg) error {
+		rc, ok := m.(*MsgReconnect)
+		if !ok {
+			return fmt.Errorf("unexpected msg type %T", m)
+		}
+		f(rc)
+		return nil
+	})
+}
+
+// OnDanmaku 弹幕消息
+func (d *Dispatcher) OnDanmaku(f func(*Danmaku)) {
+	d.onParsed(cmdDanmaku, func(raw []byte) (interface{}, error) {
+		return (&MsgDanmaku{base: base{raw: raw}}).Parse()
+	}, func(v interface{}) { f(v.(*Danmaku)) })
+}
+
+// OnGift 投喂礼物
+func (d *Dispatcher) OnGift(f func(*SendGift)) {
+	d.onParsed(cmdSendGift, func(raw []byte) (interface{}, error) {
+		return (&MsgSendGift{base: base{raw: raw}}).Parse()
+	}, func(v interface{}) { f(v.(*SendGift)) })
+}
+
+// OnSuperChat 醒目留言
+func (d *Dispatcher) OnSuperChat(f func(*SuperChatMessage)) {
+	d.onParsed(cmdSuperChatMessage, func(raw []byte) (interface{}, error) {
+		return (&MsgSuperChatMessage{base: base{raw: raw}}).Parse()
+	}, func(v interface{}) { f(v.(*SuperChatMessage)) })
+}
+
+// OnGuardBuy 用户上舰长
+func (d *Dispatcher) OnGuardBuy(f func(*GuardBuy)) {
+	d.onParsed(cmdGuardBuy, func(raw []byte) (interface{}, error) {
+		return (&MsgGuardBuy{base: base{raw: raw}}).Parse()
+	}, func(v interface{}) { f(v.(*GuardBuy)) })
+}
+
+// OnInteractWord 用户进入直播间、关注、分享
+func (d *Dispatcher) OnInteractWord(f func(*InteractWord)) {
+	d.onParsed(cmdInteractWord, func(raw []byte) (interface{}, error) {
+		return (&MsgInteractWord{base: base{raw: raw}}).Parse()
+	}, func(v interface{}) { f(v.(*InteractWord)) })
+}
+
+// OnFansUpdate 粉丝数量改变
+func (d *Dispatcher) OnFansUpdate(f func(*FansUpdate)) {
+	d.onParsed(cmdRoomRealTimeMessageUpdate, func(raw []byte) (interface{}, error) {
+		return (&MsgFansUpdate{base: base{raw: raw}}).Parse()
+	}, func(v interface{}) { f(v.(*FansUpdate)) })
+}
@@ -0,0 +1,89 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDispatcher(t *testing.T) {
+	d := NewDispatcher()
+	var (
+		gifts []string
+		raws  []string
+		msgs  int
+		errs  []error
+	)
+	d.OnGift(func(g *SendGift) { gifts = append(gifts, g.GiftName) })
+	d.OnGift(func(g *SendGift) { panic("boom") })
+	d.OnRaw("UNKNOWN_CMD", func(b []byte) { raws = append(raws, string(b)) })
+	d.OnMsg(func(Msg) { msgs++ })
+	d.OnError(func(err error) { errs = append(errs, err) })
+
+	l := NewLive(false, 0, 0, nil)
+	d.Dispatch(&Transport{Msg: l.switchCmd(cmdSendGift, []byte(`{"cmd":"SEND_GIFT","data":{"giftName":"辣条"}}`))})
+	d.Dispatch(&Transport{Msg: l.switchCmd("UNKNOWN_CMD", []byte(`{"cmd":"UNKNOWN_CMD"}`))})
+	d.Dispatch(&Transport{Msg: l.switchCmd(cmdSendGift, []byte(`{"cmd":"SEND_GIFT","data":{"giftName":1}}`))})
+	d.Dispatch(&Transport{Error: errors.New("read error")})
+
+	if len(gifts) != 1 || gifts[0] != "辣条" {
+		t.Errorf("gifts = %v", gifts)
+	}
+	if len(raws) != 1 || raws[0] != `{"cmd":"UNKNOWN_CMD"}` {
+		t.Errorf("raws = %v", raws)
+	}
+	if msgs != 3 {
+		t.Errorf("msgs = %d, want 3", msgs)
+	}
+	// 第一条礼物 panic，第三条礼物只解析一次，两个处理函数共用一个解析错误，最后是 Transport 的错误
+	if len(errs) != 3 {
+		t.Fatalf("errs = %v", errs)
+	}
+	var de *DispatchError
+	if !errors.As(errs[0], &de) || !de.Panic || de.Cmd != cmdSendGift {
+		t.Errorf("errs[0] = %v, want panic DispatchError", errs[0])
+	}
+	if !errors.As(errs[1], &de) || de.Panic {
+		t.Errorf("errs[1] = %v, want parse DispatchError", errs[1])
+	}
+	if errs[2].Error() != "read error" {
+		t.Errorf("errs[2] = %v", errs[2])
+	}
+}
+
+func TestDispatcherRunClosed(t *testing.T) {
+	d := NewDispatcher()
+	var gifts int
+	d.OnGift(func(*SendGift) { gifts++ })
+
+	rev := make(chan *Transport, 1)
+	rev <- &Transport{Msg: cmdMsg(cmdSendGift, `{"cmd":"SEND_GIFT","data":{"giftName":"辣条"}}`)}
+	close(rev)
+	done := make(chan struct{})
+	go func() {
+		d.Run(context.Background(), rev)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after rev was closed")
+	}
+	if gifts != 1 {
+		t.Errorf("gifts = %d, want 1", gifts)
+	}
+}
+
+// cmdMsg 按 cmd 构造与接收时类型相同的消息
+func cmdMsg(cmd, raw string) Msg {
+	return new(Live).switchCmd(cmd, []byte(raw))
+}
+
+// newTrackerDispatcher 注册 tracker，解析失败视为测试失败
+func newTrackerDispatcher(t *testing.T, tracker interface{ Register(*Dispatcher) }) *Dispatcher {
+	d := NewDispatcher()
+	d.OnError(func(err error) { t.Error(err) })
+	tracker.Register(d)
+	return d
+}
@@ -0,0 +1,299 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 大航海等级，对应 guard_level
+const (
+	GuardLevelNone     = 0
+	GuardLevelGovernor = 1 // 总督
+	GuardLevelAdmiral  = 2 // 提督
+	GuardLevelCaptain  = 3 // 舰长
+)
+
+// GuardLevelName 大航海等级的名称
+func GuardLevelName(level int) string {
+	switch level {
+	case GuardLevelGovernor:
+		return "总督"
+	case GuardLevelAdmiral:
+		return "提督"
+	case GuardLevelCaptain:
+		return "舰长"
+	}
+	return ""
+}
+
+// USER_TOAST_MSG 的 op_type
+const (
+	guardOpBuy       = 1 // 开通
+	guardOpRenew     = 2 // 续费
+	guardOpAutoRenew = 3 // 自动续费
+)
+
+// GuardPurchase 一次上舰，由 GUARD_BUY、USER_TOAST_MSG、GUARD_MSG 合并而来，三者不一定都会收到
+type GuardPurchase struct {
+	UID        int64 // 只有 GUARD_MSG 时为 0
+	Username   string
+	Level      int   // 参见 GuardLevelCaptain
+	Months     int   // 购买的月数
+	Price      int64 // 总价，金瓜子
+	Renewal    bool  // 续费，包括自动续费
+	StartTime  int64
+	EndTime    int64
+	GuardCount int    // 上舰后的船员数量，仅 USER_TOAST_MSG 中有
+	ToastMsg   string // USER_TOAST_MSG 中的提示
+
+	Buy   *GuardBuy     // GUARD_BUY，未收到时为 nil
+	Toast *UserToastMsg // USER_TOAST_MSG，未收到时为 nil
+	Msg   *GuardMsg     // GUARD_MSG，未收到时为 nil
+}
+
+// LevelName 大航海等级的名称
+func (p *GuardPurchase) LevelName() string {
+	return GuardLevelName(p.Level)
+}
+
+// CNY 折合人民币
+func (p *GuardPurchase) CNY() float64 {
+	return float64(p.Price) / GoldPerCNY
+}
+
+// 合并到 GuardPurchase 的消息，用作 guardPending.seen 的下标
+const (
+	guardFromBuy = iota
+	guardFromToast
+	guardFromMsg
+)
+
+type guardPending struct {
+	p       *GuardPurchase
+	first   time.Time
+	seen    [3]bool // 已收到的消息，参见 guardFromBuy
+	emitted bool
+}
+
+// match 同一等级下，双方都有 uid 时比较 uid，否则比较用户名
+func (g *guardPending) match(uid int64, uname string, level int) bool {
+	if g.p.Level != level {
+		return false
+	}
+	if uid != 0 && g.p.UID != 0 {
+		return uid == g.p.UID
+	}
+	return uname != "" && uname == g.p.Username
+}
+
+// GuardCorrelator 将同一次上舰的多个消息合并为一个 GuardPurchase。
+// 同时收到 GUARD_BUY 与 USER_TOAST_MSG 时立即触发，否则在 window 后以已收到的消息触发
+type GuardCorrelator struct {
+	mu         sync.Mutex
+	window     time.Duration
+	now        func() time.Time
+	pending    []*guardPending
+	onPurchase func(*GuardPurchase)
+	onCount    func(*NewGuardCount)
+}
+
+// NewGuardCorrelator window 为等待同一次上舰其他消息的时间，<=0 时为 3 秒。
+// now 为时间来源，nil 时使用 time.Now
+func NewGuardCorrelator(window time.Duration, now func() time.Time) *GuardCorrelator {
+	if window <= 0 {
+		window = 3 * time.Second
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &GuardCorrelator{window: window, now: now}
+}
+
+// OnPurchase 上舰，每次上舰只触发一次
+func (c *GuardCorrelator) OnPurchase(f func(*GuardPurchase)) {
+	c.mu.Lock()
+	c.onPurchase = f
+	c.mu.Unlock()
+}
+
+// OnGuardCount 船员数量改变
+func (c *GuardCorrelator) OnGuardCount(f func(*NewGuardCount)) {
+	c.mu.Lock()
+	c.onCount = f
+	c.mu.Unlock()
+}
+
+// Register 注册上舰相关 cmd
+func (c *GuardCorrelator) Register(d *Dispatcher) {
+	for _, cmd := range []string{cmdGuardBuy, cmdUserToastMsg, cmdGuardMsg, cmdNewGuardCount} {
+		d.on(cmd, c.Handle)
+	}
+}
+
+// Handle 处理一条消息，无关的消息会被忽略。每次处理前会先触发等待超时的上舰
+func (c *GuardCorrelator) Handle(m Msg) error {
+	var (
+		ps  []*GuardPurchase
+		err error
+	)
+	switch m := m.(type) {
+	case *MsgGuardBuy:
+		var b *GuardBuy
+		if b, err = m.Parse(); err == nil {
+			ps = c.add(guardFromBuy, b.UID, b.Username, b.GuardLevel, func(p *GuardPurchase) { p.Buy = b })
+		}
+	case *MsgUserToastMsg:
+		var t *UserToastMsg
+		if t, err = m.Parse(); err == nil {
+			ps = c.add(guardFromToast, t.UID, t.Username, t.GuardLevel, func(p *GuardPurchase) { p.Toast = t })
+		}
+	case *MsgGuardMsg:
+		var g *GuardMsg
+		if g, err = m.Parse(); err == nil {
+			ps = c.add(guardFromMsg, 0, g.Uname(), g.BuyType, func(p *GuardPurchase) { p.Msg = g })
+		}
+	case *MsgNewGuardCount:
+		var n *NewGuardCount
+		if n, err = m.Parse(); err == nil {
+			c.mu.Lock()
+			f := c.onCount
+			c.mu.Unlock()
+			if f != nil {
+				f(n)
+			}
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", m.Cmd(), err)
+	}
+	c.emit(ps)
+	return nil
+}
+
+// add 将消息合并到等待中的上舰，返回需要触发的上舰。
+// 已收到同类消息的上舰不再合并，例如 window 内连续两次购买会开始新的上舰
+func (c *GuardCorrelator) add(from int, uid int64, uname string, level int, set func(*GuardPurchase)) []*GuardPurchase {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ps := c.flush()
+
+	var g *guardPending
+	for _, p := range c.pending {
+		// 已触发的上舰只接受随后的 GUARD_MSG
+		if !p.seen[from] && (!p.emitted || from == guardFromMsg) && p.match(uid, uname, level) {
+			g = p
+			break
+		}
+	}
+	if g == nil {
+		g = &guardPending{p: &GuardPurchase{Level: level}, first: c.now()}
+		c.pending = append(c.pending, g)
+	}
+	g.seen[from] = true
+	if g.emitted {
+		// 已经交给 OnPurchase，不再修改或重复触发
+		return ps
+	}
+	set(g.p)
+	g.p.merge()
+	if uid != 0 {
+		g.p.UID = uid
+	}
+	if g.p.Username == "" {
+		g.p.Username = uname
+	}
+	if g.p.Buy != nil && g.p.Toast != nil {
+		g.emitted = true
+		ps = append(ps, g.p)
+	}
+	return ps
+}
+
+// merge 按 USER_TOAST_MSG、GUARD_BUY、GUARD_MSG 的优先级填充字段
+func (p *GuardPurchase) merge() {
+	if m := p.Msg; m != nil {
+		p.Renewal = strings.Contains(m.Msg, "续费")
+	}
+	if b := p.Buy; b != nil {
+		p.Username = b.Username
+		p.Months = b.Num
+		p.Price = int64(b.Price) * int64(b.Num)
+		p.StartTime, p.EndTime = b.StartTime, b.EndTime
+	}
+	if t := p.Toast; t != nil {
+		if t.Username != "" {
+			p.Username = t.Username
+		}
+		p.Months = t.Num
+		p.Price = t.Price
+		p.Renewal = t.OpType == guardOpRenew || t.OpType == guardOpAutoRenew
+		p.StartTime, p.EndTime = t.StartTime, t.EndTime
+		p.GuardCount = t.TargetGuardCount
+		p.ToastMsg = t.ToastMsg
+	}
+}
+
+// flush 触发超过 window 的上舰，并清理已触发的记录
+func (c *GuardCorrelator) flush() []*GuardPurchase {
+	now := c.now()
+	var (
+		ps   []*GuardPurchase
+		keep = c.pending[:0]
+	)
+	for _, g := range c.pending {
+		if now.Sub(g.first) < c.window {
+			keep = append(keep, g)
+			continue
+		}
+		if !g.emitted {
+			ps = append(ps, g.p)
+		}
+	}
+	for i := len(keep); i < len(c.pending); i++ {
+		c.pending[i] = nil
+	}
+	c.pending = keep
+	return ps
+}
+
+func (c *GuardCorrelator) emit(ps []*GuardPurchase) {
+	c.mu.Lock()
+	f := c.onPurchase
+	c.mu.Unlock()
+	if f == nil {
+		return
+	}
+	for _, p := range ps {
+		f(p)
+	}
+}
+
+// Flush 触发等待超时的上舰。没有新消息时需要定期调用，或使用 Run
+func (c *GuardCorrelator) Flush() {
+	c.mu.Lock()
+	ps := c.flush()
+	c.mu.Unlock()
+	c.emit(ps)
+}
+
+// Run 每隔 interval 调用一次 Flush，直到 ctx 结束。interval <= 0 时为 1s
+func (c *GuardCorrelator) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			c.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGuardCorrelator(t *testing.T) {
+	now := time.Unix(1651400000, 0)
+	c := NewGuardCorrelator(3*time.Second, func() time.Time { return now })
+	var (
+		ps    []*GuardPurchase
+		count int
+	)
+	c.OnPurchase(func(p *GuardPurchase) { ps = append(ps, p) })
+	c.OnGuardCount(func(n *NewGuardCount) { count = n.Count })
+	d := newTrackerDispatcher(t, c)
+
+	// 同一次续费的三个消息只触发一次
+	d.DispatchMsg(cmdMsg(cmdGuardBuy, `{"cmd":"GUARD_BUY","data":{"uid":2920960,"username":"一只鱼","guard_level":3,"num":3,"price":198000,"gift_id":10003,"gift_name":"舰长","start_time":1651400000,"end_time":1651400000}}`))
+	d.DispatchMsg(cmdMsg(cmdUserToastMsg, `{"cmd":"USER_TOAST_MSG","data":{"uid":2920960,"username":"一只鱼","guard_level":3,"op_type":2,"num":3,"unit":"月","price":594000,"role_name":"舰长","target_guard_count":1234,"toast_msg":"<%一只鱼%> 续费了舰长","start_time":1651400000,"end_time":1651400000}}`))
+	if len(ps) != 1 {
+		t.Fatalf("purchases = %d, want 1", len(ps))
+	}
+	d.DispatchMsg(cmdMsg(cmdGuardMsg, `{"cmd":"GUARD_MSG","msg":":?一只鱼:? 在本房间续费了舰长","msg_new":"","url":"","roomid":21852,"buy_type":3,"broadcast_type":0}`))
+	if p := ps[0]; p.UID != 2920960 || p.Level != GuardLevelCaptain || p.LevelName() != "舰长" || p.Months != 3 ||
+		p.Price != 594000 || p.CNY() != 594 || !p.Renewal || p.GuardCount != 1234 {
+		t.Errorf("purchase = %+v", p)
+	}
+
+	// 只收到 GUARD_BUY 时等待 window 后触发
+	d.DispatchMsg(cmdMsg(cmdGuardBuy, `{"cmd":"GUARD_BUY","data":{"uid":1001,"username":"路人","guard_level":2,"num":1,"price":1998000}}`))
+	now = now.Add(2 * time.Second)
+	c.Flush()
+	if len(ps) != 1 {
+		t.Fatalf("purchases = %d before window, want 1", len(ps))
+	}
+	now = now.Add(2 * time.Second)
+	c.Flush()
+	if len(ps) != 2 || ps[1].UID != 1001 || ps[1].Level != GuardLevelAdmiral || ps[1].Price != 1998000 || ps[1].Renewal || ps[1].Toast != nil {
+		t.Fatalf("purchases = %+v", ps)
+	}
+	c.Flush()
+	if len(ps) != 2 {
+		t.Errorf("purchases = %d after second flush, want 2", len(ps))
+	}
+
+	d.DispatchMsg(cmdMsg(cmdNewGuardCount, `{"cmd":"NEW_GUARD_COUNT","data":{"count":1235,"roomid":21852,"anchor_uid":546195}}`))
+	if count != 1235 {
+		t.Errorf("guard count = %d, want 1235", count)
+	}
+}
+
+func TestGuardCorrelatorRepeat(t *testing.T) {
+	now := time.Unix(1651400000, 0)
+	c := NewGuardCorrelator(3*time.Second, func() time.Time { return now })
+	var ps []*GuardPurchase
+	c.OnPurchase(func(p *GuardPurchase) { ps = append(ps, p) })
+
+	const (
+		buy   = `{"cmd":"GUARD_BUY","data":{"uid":2920960,"username":"一只鱼","guard_level":3,"num":1,"price":198000}}`
+		toast = `{"cmd":"USER_TOAST_MSG","data":{"uid":2920960,"username":"一只鱼","guard_level":3,"op_type":%d,"num":1,"price":198000,"target_guard_count":%d}}`
+		msg   = `{"cmd":"GUARD_MSG","msg":":?一只鱼:? 在本房间开通了舰长","buy_type":3}`
+	)
+	// window 内同一用户连续购买两次，各自触发一次，GUARD_MSG 不会产生第三次
+	for _, m := range []Msg{
+		cmdMsg(cmdGuardBuy, buy),
+		cmdMsg(cmdUserToastMsg, fmt.Sprintf(toast, guardOpBuy, 1234)),
+		cmdMsg(cmdGuardMsg, msg),
+		cmdMsg(cmdGuardBuy, buy),
+		cmdMsg(cmdGuardMsg, msg),
+		cmdMsg(cmdUserToastMsg, fmt.Sprintf(toast, guardOpRenew, 1234)),
+	} {
+		if err := c.Handle(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now = now.Add(5 * time.Second)
+	c.Flush()
+	if len(ps) != 2 {
+		t.Fatalf("purchases = %d, want 2", len(ps))
+	}
+	if ps[0].Renewal || !ps[1].Renewal || ps[0].Price != 198000 || ps[1].Price != 198000 {
+		t.Errorf("purchases = %+v, %+v", ps[0], ps[1])
+	}
+
+	// interval <= 0 时使用默认值，不会 panic
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Run(ctx, 0)
+}
+
+func TestGuardMsgUname(t *testing.T) {
+	for msg, want := range map[string]string{
+		":?一只鱼:? 在本房间开通了舰长": "一只鱼",
+		"在本房间开通了舰长":         "",
+		":?一只鱼":             "",
+	} {
+		if got := (&GuardMsg{Msg: msg}).Uname(); got != want {
+			t.Errorf("Uname(%q) = %q, want %q", msg, got, want)
+		}
+	}
+}
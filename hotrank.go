@@ -0,0 +1,168 @@
+package live
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type HotRankEventType int
+
+const (
+	HotRankUp      HotRankEventType = iota + 1 // 排名上升，包括进入榜单
+	HotRankDown                                // 排名下降，包括掉出榜单
+	HotRankSettled                             // 结算时位于前 N 名，N 为 Rank
+)
+
+func (t HotRankEventType) String() string {
+	switch t {
+	case HotRankUp:
+		return "up"
+	case HotRankDown:
+		return "down"
+	case HotRankSettled:
+		return "settled"
+	}
+	return "unknown"
+}
+
+// HotRankKey 榜单，按分区和榜单类型区分。部分消息中没有榜单类型，此时 Type 为空
+type HotRankKey struct {
+	Area string
+	Type string
+}
+
+// HotRankEvent 排名变化
+type HotRankEvent struct {
+	Type    HotRankEventType
+	Key     HotRankKey
+	Rank    int // 当前排名，0 为不在榜上
+	OldRank int // 之前的排名，0 为不在榜上
+	Desc    string
+	Time    time.Time
+}
+
+// HotRankTracker 根据 HOT_RANK、HOT_RANK_CHANGED、HOT_RANK_SETTLEMENT 维护本直播间在各个榜单上的排名
+type HotRankTracker struct {
+	mu      sync.Mutex
+	ranks   map[HotRankKey]int
+	onEvent func(*HotRankEvent)
+}
+
+func NewHotRankTracker() *HotRankTracker {
+	return &HotRankTracker{ranks: make(map[HotRankKey]int)}
+}
+
+// OnEvent 排名上升、下降与结算
+func (t *HotRankTracker) OnEvent(f func(*HotRankEvent)) {
+	t.mu.Lock()
+	t.onEvent = f
+	t.mu.Unlock()
+}
+
+// Rank 在榜单上的排名，0 为不在榜上或未知
+func (t *HotRankTracker) Rank(key HotRankKey) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ranks[key]
+}
+
+// Ranks 当前在榜的所有榜单
+func (t *HotRankTracker) Ranks() map[HotRankKey]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := make(map[HotRankKey]int, len(t.ranks))
+	for k, v := range t.ranks {
+		r[k] = v
+	}
+	return r
+}
+
+// Register 注册热门榜相关 cmd
+func (t *HotRankTracker) Register(d *Dispatcher) {
+	for _, cmd := range []string{cmdHotRank, cmdHotRankChanged, cmdHotRankSettlement} {
+		d.on(cmd, t.Handle)
+	}
+}
+
+// Handle 处理一条消息，无关的消息会被忽略
+func (t *HotRankTracker) Handle(m Msg) error {
+	var (
+		ev  *HotRankEvent
+		err error
+	)
+	switch m := m.(type) {
+	case *MsgHotRank:
+		var r *HotRank
+		if r, err = m.Parse(); err == nil {
+			ev = t.change(HotRankKey{Area: r.AreaName, Type: r.RankType}, r.Rank, r.RankDesc, r.Timestamp)
+		}
+	case *MsgHotRankChanged:
+		var r *HotRankChanged
+		if r, err = m.Parse(); err == nil {
+			ev = t.change(HotRankKey{Area: r.AreaName, Type: r.RankType}, r.Rank, r.RankDesc, r.Timestamp)
+		}
+	case *MsgHotRankSettlement:
+		var r *HotRankSettlement
+		if r, err = m.Parse(); err == nil {
+			ev = t.settle(HotRankKey{Area: r.AreaName, Type: r.RankType}, r.Rank, r.DmMsg, r.Timestamp)
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", m.Cmd(), err)
+	}
+	if ev == nil {
+		return nil
+	}
+	t.mu.Lock()
+	f := t.onEvent
+	t.mu.Unlock()
+	if f != nil {
+		f(ev)
+	}
+	return nil
+}
+
+func (t *HotRankTracker) change(key HotRankKey, rank int, desc string, ts int64) *HotRankEvent {
+	if rank < 0 {
+		rank = 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	old := t.ranks[key]
+	if rank == old {
+		return nil
+	}
+	if rank == 0 {
+		delete(t.ranks, key)
+	} else {
+		t.ranks[key] = rank
+	}
+	ev := &HotRankEvent{Type: HotRankDown, Key: key, Rank: rank, OldRank: old, Desc: desc, Time: unixTime(ts)}
+	// 排名数字越小越靠前，0 为不在榜上
+	if rank != 0 && (old == 0 || rank < old) {
+		ev.Type = HotRankUp
+	}
+	return ev
+}
+
+func (t *HotRankTracker) settle(key HotRankKey, rank int, desc string, ts int64) *HotRankEvent {
+	if rank <= 0 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	old := t.ranks[key]
+	t.ranks[key] = rank
+	return &HotRankEvent{Type: HotRankSettled, Key: key, Rank: rank, OldRank: old, Desc: desc, Time: unixTime(ts)}
+}
+
+// unixTime 消息中的时间戳，0 时为零值
+func unixTime(ts int64) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0)
+}
@@ -0,0 +1,81 @@
+package live
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHotRankTracker(t *testing.T) {
+	tr := NewHotRankTracker()
+	var evs []*HotRankEvent
+	tr.OnEvent(func(ev *HotRankEvent) { evs = append(evs, ev) })
+	d := newTrackerDispatcher(t, tr)
+
+	for _, m := range []Msg{
+		cmdMsg(cmdHotRankChanged, `{"cmd":"HOT_RANK_CHANGED","data":{"rank":12,"trend":1,"countdown":1705,"timestamp":1651400000,"area_name":"唱见","rank_desc":"唱见top50"}}`),
+		cmdMsg(cmdHotRankChanged, `{"cmd":"HOT_RANK_CHANGED","data":{"rank":5,"trend":1,"countdown":1500,"timestamp":1651400200,"area_name":"唱见","rank_desc":"唱见top50"}}`),
+		cmdMsg(cmdHotRankChanged, `{"cmd":"HOT_RANK_CHANGED","data":{"rank":5,"trend":0,"countdown":1400,"timestamp":1651400300,"area_name":"唱见"}}`),
+		cmdMsg(cmdHotRank, `{"cmd":"HOT_RANK","data":{"rank":30,"trend":1,"timestamp":1651400300,"area_name":"娱乐","rank_type":"parent_area"}}`),
+		cmdMsg(cmdHotRankChanged, `{"cmd":"HOT_RANK_CHANGED","data":{"rank":8,"trend":2,"countdown":1000,"timestamp":1651400700,"area_name":"唱见"}}`),
+		cmdMsg(cmdHotRankSettlement, `{"cmd":"HOT_RANK_SETTLEMENT","data":{"rank":3,"uname":"老番茄","area_name":"唱见","dm_msg":"恭喜主播 <% 老番茄%> 荣登限时热门榜唱见榜top3! 即将获得热门流量推荐哦！","timestamp":1651401800}}`),
+		cmdMsg(cmdHotRank, `{"cmd":"HOT_RANK","data":{"rank":0,"area_name":"娱乐","rank_type":"parent_area"}}`),
+	} {
+		d.DispatchMsg(m)
+	}
+
+	var types []string
+	for _, ev := range evs {
+		types = append(types, ev.Type.String())
+	}
+	if got := strings.Join(types, ","); got != "up,up,up,down,settled,down" {
+		t.Fatalf("events = %s", got)
+	}
+	if ev := evs[1]; ev.Rank != 5 || ev.OldRank != 12 || ev.Key != (HotRankKey{Area: "唱见"}) || ev.Time.Unix() != 1651400200 {
+		t.Errorf("rank up = %+v", ev)
+	}
+	if ev := evs[4]; ev.Rank != 3 || ev.OldRank != 8 || !strings.Contains(ev.Desc, "top3") {
+		t.Errorf("settled = %+v", ev)
+	}
+	if ev := evs[5]; ev.Rank != 0 || ev.OldRank != 30 || ev.Key.Type != "parent_area" {
+		t.Errorf("off board = %+v", ev)
+	}
+	if ranks := tr.Ranks(); len(ranks) != 1 || tr.Rank(HotRankKey{Area: "唱见"}) != 3 {
+		t.Errorf("Ranks() = %v", ranks)
+	}
+}
+
+func TestHotRankTrackerEdgeCases(t *testing.T) {
+	tr := NewHotRankTracker()
+	var evs []*HotRankEvent
+	tr.OnEvent(func(ev *HotRankEvent) { evs = append(evs, ev) })
+	for _, raw := range [][2]string{
+		// 不在榜上时 rank 为 -1 或 0 不触发事件
+		{cmdHotRankChanged, `{"cmd":"HOT_RANK_CHANGED","data":{"rank":-1,"area_name":"唱见"}}`},
+		{cmdHotRankSettlement, `{"cmd":"HOT_RANK_SETTLEMENT","data":{"rank":0,"area_name":"唱见"}}`},
+		// 分区榜与父分区榜分别记录
+		{cmdHotRankChanged, `{"cmd":"HOT_RANK_CHANGED","data":{"rank":12,"area_name":"唱见"}}`},
+		{cmdHotRank, `{"cmd":"HOT_RANK","data":{"rank":12,"area_name":"唱见","rank_type":"parent_area"}}`},
+		// 重复结算同一名次仍会通知
+		{cmdHotRankSettlement, `{"cmd":"HOT_RANK_SETTLEMENT","data":{"rank":12,"area_name":"唱见"}}`},
+	} {
+		if err := tr.Handle(cmdMsg(raw[0], raw[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(evs) != 3 || evs[0].Key == evs[1].Key || evs[2].Type != HotRankSettled || evs[2].OldRank != 12 || !evs[0].Time.IsZero() {
+		t.Errorf("events = %+v", evs)
+	}
+	if len(tr.Ranks()) != 2 {
+		t.Errorf("Ranks() = %v", tr.Ranks())
+	}
+}
+
+func TestMsgHotRoomNotifyParse(t *testing.T) {
+	n, err := (&MsgHotRoomNotify{base{raw: []byte(`{"cmd":"HOT_ROOM_NOTIFY","data":{"threshold":10000,"ttl":300,"exit_no_refresh":0,"random_delay_req_v2":[{"path":"/live/getRoundPlayVideo","delay":30000}]}}`)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Threshold != 10000 || n.TTL != 300 || len(n.RandomDelayReqV2) != 1 || n.RandomDelayReqV2[0].Delay != 30000 {
+		t.Errorf("HOT_ROOM_NOTIFY = %+v", n)
+	}
+}
@@ -0,0 +1,318 @@
+package live
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// GoldPerCNY 1 元人民币 = 1000 金瓜子
+const GoldPerCNY = 1000
+
+const (
+	CoinGold   = "gold"
+	CoinSilver = "silver"
+)
+
+// GiftTotal 礼物合计。银瓜子没有实际价值，不计入 CNY
+type GiftTotal struct {
+	Gold   int64 // 金瓜子，醒目留言按 1 元 = 1000 金瓜子折算
+	Silver int64 // 银瓜子
+	Num    int64 // 礼物个数，上舰为月数，醒目留言为条数
+}
+
+// CNY 折合人民币
+func (t GiftTotal) CNY() float64 {
+	return float64(t.Gold) / GoldPerCNY
+}
+
+func (t *GiftTotal) add(coin string, v, num int64) {
+	if coin == CoinSilver {
+		t.Silver += v
+	} else {
+		t.Gold += v
+	}
+	t.Num += num
+}
+
+// UserTotal 单个用户的合计
+type UserTotal struct {
+	UID   int64
+	Uname string
+	GiftTotal
+}
+
+// GiftStat 单个礼物的合计
+type GiftStat struct {
+	GiftID   int64
+	GiftName string
+	GiftTotal
+}
+
+// LedgerReport GiftLedger 的汇总，Users、Gifts 按金瓜子从高到低排序
+type LedgerReport struct {
+	Total     GiftTotal
+	Gift      GiftTotal // 普通礼物
+	Guard     GiftTotal // 上舰
+	SuperChat GiftTotal // 醒目留言
+	Users     []UserTotal
+	Gifts     []GiftStat
+}
+
+// comboBatch 同一 batch_combo_id 下已经计入的数量。
+// SEND_GIFT 可能被合并或丢失，COMBO_SEND 给出的是截至当前的总数，两者取大
+type comboBatch struct {
+	coin       string
+	gifts, num int64 // SEND_GIFT 累计
+	combo      int64 // COMBO_SEND 最新的 combo_total_coin
+	comboNum   int64 // COMBO_SEND 最新的 total_num
+	counted    int64 // 已计入的价值
+	countedNum int64 // 已计入的个数
+	seen       time.Time
+}
+
+// GiftLedger 统计礼物、上舰、醒目留言的收入。
+// 连击礼物会同时下发逐个的 SEND_GIFT 和汇总的 COMBO_SEND，二者通过 batch_combo_id 去重，
+// 超过 ttl 没有新消息的 batch_combo_id 会被清理，之后再收到时按新的连击计入
+type GiftLedger struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	now       func() time.Time
+	swept     time.Time // 上次清理 batches 的时间
+	total     GiftTotal
+	gift      GiftTotal
+	guard     GiftTotal
+	superChat GiftTotal
+	users     map[int64]*UserTotal
+	gifts     map[int64]*GiftStat
+	batches   map[string]*comboBatch
+}
+
+// NewGiftLedger ttl 为连击记录的保留时间，<=0 时为 10min。now 为时间来源，nil 时使用 time.Now
+func NewGiftLedger(ttl time.Duration, now func() time.Time) *GiftLedger {
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	if now == nil {
+		now = time.Now
+	}
+	l := &GiftLedger{ttl: ttl, now: now}
+	l.Reset()
+	return l
+}
+
+// Reset 清空统计，例如新的一场直播开始时
+func (l *GiftLedger) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total, l.gift, l.guard, l.superChat = GiftTotal{}, GiftTotal{}, GiftTotal{}, GiftTotal{}
+	l.users = make(map[int64]*UserTotal)
+	l.gifts = make(map[int64]*GiftStat)
+	l.batches = make(map[string]*comboBatch)
+	l.swept = l.now()
+}
+
+// Register 注册礼物、上舰、醒目留言
+func (l *GiftLedger) Register(d *Dispatcher) {
+	for _, cmd := range []string{cmdSendGift, cmdComboSend, cmdGuardBuy, cmdSuperChatMessage} {
+		d.on(cmd, l.Handle)
+	}
+}
+
+// Handle 处理一条消息，无关的消息会被忽略
+func (l *GiftLedger) Handle(m Msg) error {
+	var err error
+	switch m := m.(type) {
+	case *MsgSendGift:
+		var g *SendGift
+		if g, err = m.Parse(); err == nil {
+			l.AddGift(g)
+		}
+	case *MsgComboSend:
+		var c *ComboSend
+		if c, err = m.Parse(); err == nil {
+			l.AddCombo(c)
+		}
+	case *MsgGuardBuy:
+		var g *GuardBuy
+		if g, err = m.Parse(); err == nil {
+			l.AddGuard(g)
+		}
+	case *MsgSuperChatMessage:
+		var sc *SuperChatMessage
+		if sc, err = m.Parse(); err == nil {
+			l.AddSuperChat(sc)
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", m.Cmd(), err)
+	}
+	return nil
+}
+
+// AddGift 计入一条 SEND_GIFT
+func (l *GiftLedger) AddGift(g *SendGift) {
+	v := int64(g.TotalCoin)
+	if v == 0 {
+		v = int64(g.Price) * int64(g.Num)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if g.BatchComboID == "" {
+		l.add(&l.gift, g.CoinType, g.UID, g.Uname, g.GiftID, g.GiftName, v, int64(g.Num))
+		return
+	}
+	b := l.batch(g.BatchComboID)
+	b.coin = g.CoinType
+	b.gifts += v
+	b.num += int64(g.Num)
+	l.settle(b, g.UID, g.Uname, g.GiftID, g.GiftName)
+}
+
+// AddCombo 计入一条 COMBO_SEND，与已计入的 SEND_GIFT 只取差额
+func (l *GiftLedger) AddCombo(c *ComboSend) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c.BatchComboID == "" {
+		l.add(&l.gift, CoinGold, c.UID, c.Uname, c.GiftID, c.GiftName, int64(c.ComboTotalCoin), int64(c.TotalNum))
+		return
+	}
+	b := l.batch(c.BatchComboID)
+	if v := int64(c.ComboTotalCoin); v > b.combo {
+		b.combo = v
+	}
+	if n := int64(c.TotalNum); n > b.comboNum {
+		b.comboNum = n
+	}
+	l.settle(b, c.UID, c.Uname, c.GiftID, c.GiftName)
+}
+
+// AddGuard 计入一条 GUARD_BUY，price 为单价，单位金瓜子
+func (l *GiftLedger) AddGuard(g *GuardBuy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	num := int64(g.Num)
+	if num == 0 {
+		num = 1
+	}
+	l.add(&l.guard, CoinGold, g.UID, g.Username, g.GiftID, g.GiftName, int64(g.Price)*num, num)
+}
+
+// AddSuperChat 计入一条 SUPER_CHAT_MESSAGE，price 单位为元
+func (l *GiftLedger) AddSuperChat(sc *SuperChatMessage) {
+	name := sc.Gift.GiftName
+	if name == "" {
+		name = "醒目留言"
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.add(&l.superChat, CoinGold, sc.UID, sc.UserInfo.Uname, sc.Gift.GiftID, name, int64(sc.Price)*GoldPerCNY, 1)
+}
+
+func (l *GiftLedger) batch(id string) *comboBatch {
+	b, ok := l.batches[id]
+	if !ok {
+		b = &comboBatch{}
+		l.batches[id] = b
+	}
+	return b
+}
+
+// settle 计入 batch 新增的部分，并清理过期的 batch
+func (l *GiftLedger) settle(b *comboBatch, uid int64, uname string, giftID int64, giftName string) {
+	now := l.now()
+	b.seen = now
+	if now.Sub(l.swept) >= l.ttl {
+		for id, old := range l.batches {
+			if now.Sub(old.seen) >= l.ttl {
+				delete(l.batches, id)
+			}
+		}
+		l.swept = now
+	}
+
+	v, num := b.gifts, b.num
+	if b.combo > v {
+		v = b.combo
+	}
+	if b.comboNum > num {
+		num = b.comboNum
+	}
+	if v == b.counted && num == b.countedNum {
+		return
+	}
+	l.add(&l.gift, b.coin, uid, uname, giftID, giftName, v-b.counted, num-b.countedNum)
+	b.counted, b.countedNum = v, num
+}
+
+func (l *GiftLedger) add(kind *GiftTotal, coin string, uid int64, uname string, giftID int64, giftName string, v, num int64) {
+	kind.add(coin, v, num)
+	l.total.add(coin, v, num)
+
+	u, ok := l.users[uid]
+	if !ok {
+		u = &UserTotal{UID: uid}
+		l.users[uid] = u
+	}
+	if uname != "" {
+		u.Uname = uname
+	}
+	u.add(coin, v, num)
+
+	g, ok := l.gifts[giftID]
+	if !ok {
+		g = &GiftStat{GiftID: giftID}
+		l.gifts[giftID] = g
+	}
+	if giftName != "" {
+		g.GiftName = giftName
+	}
+	g.add(coin, v, num)
+}
+
+// User 单个用户的合计
+func (l *GiftLedger) User(uid int64) (UserTotal, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	u, ok := l.users[uid]
+	if !ok {
+		return UserTotal{}, false
+	}
+	return *u, true
+}
+
+// Total 全部收入的合计
+func (l *GiftLedger) Total() GiftTotal {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total
+}
+
+// Report 当前的汇总
+func (l *GiftLedger) Report() *LedgerReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := &LedgerReport{Total: l.total, Gift: l.gift, Guard: l.guard, SuperChat: l.superChat}
+	for _, u := range l.users {
+		r.Users = append(r.Users, *u)
+	}
+	for _, g := range l.gifts {
+		r.Gifts = append(r.Gifts, *g)
+	}
+	sort.Slice(r.Users, func(i, j int) bool {
+		if r.Users[i].Gold != r.Users[j].Gold {
+			return r.Users[i].Gold > r.Users[j].Gold
+		}
+		return r.Users[i].UID < r.Users[j].UID
+	})
+	sort.Slice(r.Gifts, func(i, j int) bool {
+		if r.Gifts[i].Gold != r.Gifts[j].Gold {
+			return r.Gifts[i].Gold > r.Gifts[j].Gold
+		}
+		return r.Gifts[i].GiftID < r.Gifts[j].GiftID
+	})
+	return r
+}
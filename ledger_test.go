@@ -0,0 +1,101 @@
+package live
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGiftLedger(t *testing.T) {
+	gift := func(uid int64, coin string, giftID, num, total int, batch string) Msg {
+		return cmdMsg(cmdSendGift, fmt.Sprintf(`{"cmd":"SEND_GIFT","data":{"uid":%d,"uname":"u%d","coin_type":%q,"giftId":%d,"giftName":"g%d","num":%d,"total_coin":%d,"batch_combo_id":%q}}`,
+			uid, uid, coin, giftID, giftID, num, total, batch))
+	}
+	combo := func(uid int64, giftID, num, total int, batch string) Msg {
+		return cmdMsg(cmdComboSend, fmt.Sprintf(`{"cmd":"COMBO_SEND","data":{"uid":%d,"uname":"u%d","gift_id":%d,"gift_name":"g%d","total_num":%d,"combo_total_coin":%d,"batch_combo_id":%q}}`,
+			uid, uid, giftID, giftID, num, total, batch))
+	}
+
+	g := NewGiftLedger(0, nil)
+	d := newTrackerDispatcher(t, g)
+	for _, m := range []Msg{
+		// 连击：3 个 SEND_GIFT 后 COMBO_SEND 汇总为 5 个，只补计差额；重复的 COMBO_SEND 不再计入
+		gift(1, CoinGold, 31036, 1, 100, "b1"),
+		gift(1, CoinGold, 31036, 1, 100, "b1"),
+		gift(1, CoinGold, 31036, 1, 100, "b1"),
+		combo(1, 31036, 5, 500, "b1"),
+		combo(1, 31036, 5, 500, "b1"),
+		// COMBO_SEND 先于 SEND_GIFT 到达
+		combo(2, 31036, 2, 200, "b2"),
+		gift(2, CoinGold, 31036, 1, 100, "b2"),
+		// 银瓜子礼物
+		gift(2, CoinSilver, 1, 10, 1000, ""),
+		cmdMsg(cmdGuardBuy, `{"cmd":"GUARD_BUY","data":{"uid":2,"username":"u2","guard_level":3,"num":2,"price":198000,"gift_id":10003,"gift_name":"舰长"}}`),
+		cmdMsg(cmdSuperChatMessage, `{"cmd":"SUPER_CHAT_MESSAGE","data":{"id":1,"uid":3,"price":30,"message":"hi","gift":{"gift_id":12000,"gift_name":"醒目留言","num":1},"user_info":{"uname":"u3"}}}`),
+	} {
+		d.DispatchMsg(m)
+	}
+
+	r := g.Report()
+	if r.Gift != (GiftTotal{Gold: 700, Silver: 1000, Num: 17}) {
+		t.Errorf("Gift = %+v", r.Gift)
+	}
+	if r.Guard != (GiftTotal{Gold: 396000, Num: 2}) || r.SuperChat != (GiftTotal{Gold: 30000, Num: 1}) {
+		t.Errorf("Guard = %+v, SuperChat = %+v", r.Guard, r.SuperChat)
+	}
+	if r.Total.Gold != 426700 || r.Total.CNY() != 426.7 {
+		t.Errorf("Total = %+v, CNY = %v", r.Total, r.Total.CNY())
+	}
+	if len(r.Users) != 3 || r.Users[0].UID != 2 || r.Users[0].Gold != 396200 || r.Users[0].Silver != 1000 {
+		t.Errorf("Users = %+v", r.Users)
+	}
+	if u, ok := g.User(1); !ok || u.Gold != 500 || u.Num != 5 || u.Uname != "u1" {
+		t.Errorf("User(1) = %+v, %v", u, ok)
+	}
+	if r.Gifts[0].GiftID != 10003 || r.Gifts[len(r.Gifts)-1].GiftID != 1 {
+		t.Errorf("Gifts = %+v", r.Gifts)
+	}
+
+	g.Reset()
+	if g.Total() != (GiftTotal{}) {
+		t.Errorf("Total after Reset = %+v", g.Total())
+	}
+}
+
+func TestGiftLedgerEvict(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	g := NewGiftLedger(time.Minute, func() time.Time { return now })
+	gift := func(batch string) *SendGift {
+		return &SendGift{UID: 1, CoinType: CoinGold, GiftID: 31036, Num: 1, TotalCoin: 100, BatchComboID: batch}
+	}
+
+	g.AddGift(gift("b1"))
+	now = now.Add(30 * time.Second)
+	g.AddGift(gift("b2"))
+	now = now.Add(40 * time.Second)
+	// b1 已超过 1min 没有新消息，b2 仍在保留时间内
+	g.AddCombo(&ComboSend{UID: 1, GiftID: 31036, TotalNum: 2, ComboTotalCoin: 200, BatchComboID: "b2"})
+	if _, ok := g.batches["b1"]; ok || len(g.batches) != 1 {
+		t.Errorf("batches = %v", g.batches)
+	}
+	if tot := g.Total(); tot.Gold != 300 || tot.Num != 3 {
+		t.Errorf("Total = %+v", tot)
+	}
+
+	// 清理后再收到 b1 时按新的连击计入
+	g.AddCombo(&ComboSend{UID: 1, GiftID: 31036, TotalNum: 3, ComboTotalCoin: 300, BatchComboID: "b1"})
+	if tot := g.Total(); tot.Gold != 600 || tot.Num != 6 {
+		t.Errorf("Total after b1 returns = %+v", tot)
+	}
+}
+
+func TestGiftLedgerComboShrink(t *testing.T) {
+	g := NewGiftLedger(0, nil)
+	// 乱序到达的 COMBO_SEND 汇总比已计入的少时不回退
+	g.AddCombo(&ComboSend{UID: 1, GiftID: 31036, TotalNum: 5, ComboTotalCoin: 500, BatchComboID: "b1"})
+	g.AddCombo(&ComboSend{UID: 1, GiftID: 31036, TotalNum: 3, ComboTotalCoin: 300, BatchComboID: "b1"})
+	g.AddGift(&SendGift{UID: 1, CoinType: CoinGold, GiftID: 31036, Num: 1, TotalCoin: 100, BatchComboID: "b1"})
+	if tot := g.Total(); tot.Gold != 500 || tot.Num != 5 {
+		t.Errorf("Total = %+v", tot)
+	}
+}
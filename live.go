@@ -4,80 +4,342 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 type Live struct {
-	ws      *websocket.Conn
-	debug   bool
-	logger  *log.Logger
-	entered chan struct{}
-	hb      time.Duration
-	recover func(error)
-	Rev     chan *Transport
+	ws        *websocket.Conn
+	dialer    *websocket.Dialer
+	hosts     []string
+	hostIdx   int            // 当前使用的 hosts 下标
+	header    http.Header    // 握手时附带的请求头
+	resp      *http.Response // 最近一次握手的响应
+	mu        sync.RWMutex   // 保护 hostIdx、resp、room、attempt 与 rc
+	room      int64          // 日志字段
+	attempt   int            // 日志字段，当前重连次数
+	timeout   time.Duration  // 单个 host 的连接超时
+	logger    Logger
+	entered   chan struct{}
+	hb        time.Duration
+	recover   func(error)
+	reconnect *ReconnectPolicy
+	protover  int
+	workers   int // 解码协程数，0 表示在接收协程中解码
+	bp        Backpressure
+	queue     *queue // BackpressureDropOldest 与 BackpressureSpill 时使用
+	stats     Stats
+	statsMu   sync.Mutex
+	filters   []func(Msg) bool
+	cache     int           // Rev 的缓存
+	stalled   time.Time     // BackpressureDropNewest 时 Rev 等待超时后，到此时间前不再等待，只在接收协程中访问
+	rc        *MsgReconnect // 重连后待投递的 MsgReconnect
+	Rev       chan *Transport
 }
 
-// NewLive 创建一个新的直播连接
+// ReconnectPolicy 断线重连策略，重连间隔按指数退避增长
+type ReconnectPolicy struct {
+	MaxAttempts int                          // 连续重连的最大次数，<=0 表示不限制
+	MinDelay    time.Duration                // 首次重连前的等待时间
+	MaxDelay    time.Duration                // 等待时间上限
+	Multiplier  float64                      // 每次重连等待时间的增长倍数，<=1 时按 2 处理
+	Jitter      float64                      // 随机抖动比例，取值 [0,1]
+	OnAttempt   func(attempt int, err error) // 每次重连前调用，err 为导致断线或上次重连失败的错误
+}
+
+// DefaultReconnectPolicy 返回一个默认的重连策略：1s 起步，最长 1min，不限次数
+func DefaultReconnectPolicy() *ReconnectPolicy {
+	return &ReconnectPolicy{
+		MinDelay:   time.Second,
+		MaxDelay:   time.Minute,
+		Multiplier: 2,
+		Jitter:     0.2,
+	}
+}
+
+// delay 第 attempt 次重连前的等待时间，attempt 从 1 开始
+func (p *ReconnectPolicy) delay(attempt int) time.Duration {
+	mul := p.Multiplier
+	if mul <= 1 {
+		mul = 2
+	}
+	d := float64(p.MinDelay)
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < float64(p.MaxDelay)); i++ {
+		d *= mul
+	}
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(d)
+}
+
+// NewLive 创建一个新的直播连接，等价于使用 WithDebug、WithHeartbeat、WithBuffer、WithRecover 调用 New。
+// heartbeat <= 0 时使用默认的 30s
 func NewLive(debug bool, heartbeat time.Duration, cache int, recover func(error)) *Live {
-	return &Live{
-		ws:      nil,
-		debug:   debug,
-		logger:  log.New(os.Stdout, "Live ", log.LstdFlags|log.Lshortfile),
-		hb:      heartbeat,
-		entered: make(chan struct{}),
-		recover: recover,
-		Rev:     make(chan *Transport, cache),
+	opts := []Option{WithDebug(debug), WithBuffer(cache), WithRecover(recover)}
+	if heartbeat > 0 {
+		opts = append(opts, WithHeartbeat(heartbeat))
+	}
+	l, _ := New(opts...)
+	return l
+}
+
+// SetReconnect 设置断线重连策略，需在 Enter 前调用。传入 nil 关闭重连
+func (l *Live) SetReconnect(p *ReconnectPolicy) {
+	l.reconnect = p
+}
+
+// SetProtover 设置进房时协商的协议版本，可选 ProtoverPlain、ProtoverZlib、ProtoverBrotli，需在 Enter 前调用
+func (l *Live) SetProtover(v int) error {
+	switch v {
+	case ProtoverPlain, ProtoverZlib, ProtoverBrotli:
+		l.protover = v
+		return nil
 	}
+	return fmt.Errorf("unsupported protover: %d", v)
+}
+
+// SetParseWorkers 设置并行解码消息的协程数，需在 Enter 前调用。
+// 默认为 0，即在接收协程中解码。无论是否并行，投递到 Rev 的顺序都与接收顺序一致
+func (l *Live) SetParseWorkers(n int) {
+	l.workers = n
 }
 
 // Conn ws连接bilibili弹幕服务器
 func (l *Live) Conn(dialer *websocket.Dialer, host string) error {
-	w, _, err := dialer.Dial(host, nil)
+	return l.ConnContext(context.Background(), dialer, host, nil)
+}
+
+// ConnContext 同 Conn，ctx 可用于取消连接，header 会在握手时发送(如 Cookie、User-Agent、Origin)。
+// 握手被服务器拒绝时返回 *HandshakeError
+func (l *Live) ConnContext(ctx context.Context, dialer *websocket.Dialer, host string, header http.Header) error {
+	return l.ConnHostsContext(ctx, dialer, []string{host}, header, 0)
+}
+
+// ConnHosts 按顺序尝试连接 hosts 直到成功，hosts 通常来自 DanmuInfo.WssHosts。
+// timeout 为单个 host 的连接超时，<=0 表示不限制。
+// 断线重连时从上一次成功的 host 的下一个开始轮换
+func (l *Live) ConnHosts(dialer *websocket.Dialer, hosts []string, timeout time.Duration) error {
+	return l.ConnHostsContext(context.Background(), dialer, hosts, nil, timeout)
+}
+
+// ConnHostsContext 同 ConnHosts，header 在每次握手(包括重连)时发送
+func (l *Live) ConnHostsContext(ctx context.Context, dialer *websocket.Dialer, hosts []string, header http.Header, timeout time.Duration) error {
+	if len(hosts) == 0 {
+		return errors.New("no host to connect")
+	}
+	l.dialer = dialer
+	l.hosts = hosts
+	l.header = header
+	l.timeout = timeout
+	l.setHostIdx(len(hosts) - 1)
+	return l.dial(ctx)
+}
+
+// HandshakeError websocket 握手被服务器拒绝，如 403、412
+type HandshakeError struct {
+	Host       string
+	StatusCode int
+	Status     string
+	Body       []byte // 响应体，最多 1KB
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("websocket handshake with %s failed: %s", e.Host, e.Status)
+}
+
+// Host 返回当前连接使用的 host
+func (l *Live) Host() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if len(l.hosts) == 0 {
+		return ""
+	}
+	return l.hosts[l.hostIdx]
+}
+func (l *Live) setHostIdx(i int) {
+	l.mu.Lock()
+	l.hostIdx = i
+	l.mu.Unlock()
+}
+
+// Response 返回最近一次握手的 HTTP 响应，包括失败的握手。响应体已被读取
+func (l *Live) Response() *http.Response {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.resp
+}
+
+// dial 从当前 host 的下一个开始依次尝试，每个 host 最多尝试一次
+func (l *Live) dial(ctx context.Context) error {
+	var err error
+	for i := 1; i <= len(l.hosts); i++ {
+		idx := (l.hostIdx + i) % len(l.hosts)
+		if err = l.dialHost(ctx, l.hosts[idx]); err == nil {
+			l.setHostIdx(idx)
+			l.info("connected")
+			return nil
+		}
+		l.warn("failed to connect", "target", l.hosts[idx], "err", err)
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+func (l *Live) dialHost(ctx context.Context, host string) error {
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+	w, resp, err := l.dialer.DialContext(ctx, host, l.header)
+	if resp != nil {
+		l.mu.Lock()
+		l.resp = resp
+		l.mu.Unlock()
+	}
 	if err != nil {
+		if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			return &HandshakeError{Host: host, StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+		}
 		return err
 	}
 	l.ws = w
 	return nil
 }
 
-// TODO 错误重试机制
+// EnterOptions 进房包参数
+type EnterOptions struct {
+	Key      string                 // 用户标识，通常为 DanmuInfo.Token
+	UID      int64                  // 用户UID，匿名时用户名会被打码
+	Buvid    string                 // 设备标识，对应 cookie 中的 buvid3
+	Platform string                 // 为空时使用 web
+	Extra    map[string]interface{} // 额外字段，会覆盖同名字段
+}
 
 // Enter 进入房间。 Conn 后五秒内必须进入房间，否则服务器主动断开连接
+//
+// 设置了 ReconnectPolicy 时，连接异常断开后会轮换到下一个 host 重新连接并再次进入房间，
+// 成功后向 Rev 推送 MsgReconnect。Rev 始终是同一个 channel
 func (l *Live) Enter(ctx context.Context, room int64, key string, uid int64) error {
+	return l.EnterWithOptions(ctx, room, &EnterOptions{Key: key, UID: uid})
+}
+
+// EnterWithOptions 同 Enter，可以携带 buvid 等登录凭据，参见 EnterOptionsFromCookies
+func (l *Live) EnterWithOptions(ctx context.Context, room int64, opts *EnterOptions) error {
+	l.mu.Lock()
+	l.room = room
+	l.mu.Unlock()
+
+	if l.queue != nil {
+		// 缓冲中未投递的消息会在下次 Enter 时继续投递
+		fctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go l.queue.forward(fctx)
+	}
+
+	var rc *MsgReconnect
+	for attempt := 0; ; {
+		entered, err := l.enter(ctx, room, opts, rc)
+		if err == nil || ctx.Err() != nil || l.reconnect == nil {
+			return err
+		}
+		if entered {
+			attempt = 0
+		}
+		l.setAttempt(attempt)
+		reason := err
+		for {
+			attempt++
+			if l.reconnect.MaxAttempts > 0 && attempt > l.reconnect.MaxAttempts {
+				return err
+			}
+			if l.reconnect.OnAttempt != nil {
+				l.reconnect.OnAttempt(attempt, err)
+			}
+			l.setAttempt(attempt)
+			d := l.reconnect.delay(attempt)
+			l.info("reconnecting", "delay", d, "reason", err)
+			if !sleep(ctx, d) {
+				return nil
+			}
+			if err = l.dial(ctx); err == nil {
+				break
+			}
+			l.warn("reconnect failed", "err", err)
+		}
+		rc = &MsgReconnect{Attempt: attempt, Reason: reason, Host: l.Host()}
+	}
+}
+
+// enter 发送进房包并维持连接直到断开。entered 表示本次是否成功进入了房间
+func (l *Live) enter(ctx context.Context, room int64, opts *EnterOptions, rc *MsgReconnect) (entered bool, err error) {
+	platform := opts.Platform
+	if platform == "" {
+		platform = "web"
+	}
 	enter := map[string]interface{}{
-		"platform": "web",
-		"protover": 2,
+		"platform": platform,
+		"protover": l.protover,
 		"roomid":   room,
-		"uid":      uid,
+		"uid":      opts.UID,
 		"type":     2,
-		"key":      key,
+		"key":      opts.Key,
+	}
+	if opts.Buvid != "" {
+		enter["buvid"] = opts.Buvid
+	}
+	for k, v := range opts.Extra {
+		enter[k] = v
 	}
 	body, err := json.Marshal(enter)
 	if err != nil {
-		return err
+		return false, err
 	}
-	if err = l.ws.WriteMessage(websocket.BinaryMessage, encode(wsVerPlain, wsOpEnterRoom, body)); err != nil {
-		return err
+	// 重连时 l.ws 会被替换，本次连接的协程只使用这里取到的 ws
+	ws := l.ws
+	// rc 由接收协程在进房成功后、本连接的第一条消息之前投递，保证与接收顺序一致
+	l.mu.Lock()
+	l.rc = rc
+	l.mu.Unlock()
+	if err = ws.WriteMessage(websocket.BinaryMessage, encode(wsVerPlain, wsOpEnterRoom, body)); err != nil {
+		return false, err
 	}
 
 	hbCtx, hbCancel := context.WithCancel(ctx)
 	revCtx, revCancel := context.WithCancel(ctx)
-	ifError := make(chan error)
-	go l.revWithError(revCtx, ifError)
-	<-l.entered
-	go l.heartbeat(hbCtx, l.hb)
+	ifError := make(chan error, 1)
+	go l.revWithError(revCtx, ws, ifError)
 
 	defer func() {
 		hbCancel()
 		revCancel()
-		err = l.ws.Close()
+		_ = ws.Close()
 	}()
 
+	select {
+	case <-ctx.Done():
+		l.info("websocket conn stopped")
+		return false, nil
+	case err = <-ifError:
+		l.error("websocket conn stopped before entering room", "err", err)
+		return false, err
+	case <-l.entered:
+	}
+	go l.heartbeat(hbCtx, ws, l.hb)
+
 	select {
 	// 外部停止ws
 	case <-ctx.Done():
@@ -85,11 +347,23 @@ func (l *Live) Enter(ctx context.Context, room int64, key string, uid int64) err
 		break
 	// 內部接收 Websocket 訊息錯誤
 	case err = <-ifError:
-		l.error("websocket conn stopped with an error: %s", err)
+		l.error("websocket conn stopped with an error", "err", err)
 		break
 	}
 
-	return err
+	return true, err
+}
+
+// sleep 等待 d，ctx 结束时提前返回 false
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
 }
 func (l *Live) report() {
 	if r := recover(); r != nil {
@@ -104,12 +378,12 @@ func (l *Live) report() {
 		if l.recover != nil {
 			l.recover(e)
 		}
-		l.error("panic: %s", e)
+		l.error("panic", "err", e)
 	}
 }
-func (l *Live) heartbeat(ctx context.Context, t time.Duration) {
+func (l *Live) heartbeat(ctx context.Context, ws *websocket.Conn, t time.Duration) {
 	hb := func(live *Live) {
-		err := live.ws.WriteMessage(websocket.BinaryMessage, encode(wsVerPlain, wsOpHeartbeat, nil))
+		err := ws.WriteMessage(websocket.BinaryMessage, encode(wsVerPlain, wsOpHeartbeat, nil))
 		if err != nil {
 			live.push(ctx, nil, fmt.Errorf("failed to send hearbeat: %s", err))
 		}
@@ -122,7 +396,7 @@ func (l *Live) heartbeat(ctx context.Context, t time.Duration) {
 	for {
 		select {
 		case <-ctx.Done():
-			l.info("heartbeat stopped")
+			l.debug("heartbeat stopped")
 			return
 		case <-ticker.C:
 			hb(l)
@@ -130,86 +404,183 @@ func (l *Live) heartbeat(ctx context.Context, t time.Duration) {
 	}
 }
 
-// revWithError 接收訊息並捕捉錯誤
-func (l *Live) revWithError(ctx context.Context, ifError chan<- error) {
-	msgCtx, msgCancel := context.WithCancel(ctx)
-	defer l.info("receiving stopped")
-	defer msgCancel()
+// revWithError 接收訊息並捕捉錯誤。消息按接收顺序解码并投递到 Rev
+func (l *Live) revWithError(ctx context.Context, ws *websocket.Conn, ifError chan<- error) {
+	defer l.debug("receiving stopped")
+
+	handle := func(b []byte) {
+		l.deliver(ctx, l.handle(b))
+	}
+	if l.workers > 0 {
+		var wait func()
+		handle, wait = l.parallel(ctx, l.workers)
+		defer wait()
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			if t, msg, err := l.ws.ReadMessage(); t == websocket.BinaryMessage && err == nil && len(msg) > 16 {
-				go l.handle(msgCtx, msg)
+			if t, msg, err := ws.ReadMessage(); t == websocket.BinaryMessage && err == nil && len(msg) > wsPackHeaderTotalLen {
+				handle(msg)
 			} else if err != nil {
-				go func() {
-					ifError <- err
-				}()
+				ifError <- err
 				return
 			}
 		}
 	}
 }
 
-func (l *Live) handle(ctx context.Context, b []byte) {
+// frame 一帧解码后的结果，msgs 与包内顺序一致
+type frame struct {
+	entered bool
+	msgs    []*Transport
+}
+
+func (f *frame) add(m Msg, err error) {
+	f.msgs = append(f.msgs, &Transport{Msg: m, Error: err})
+}
+
+// parallel 启动 n 个协程解码，返回的 handle 需在同一个协程中调用，结果按调用顺序投递。
+// wait 在不再调用 handle 后调用，等待已接收的帧投递完毕
+func (l *Live) parallel(ctx context.Context, n int) (handle func([]byte), wait func()) {
+	type job struct {
+		b   []byte
+		res chan<- *frame
+	}
+	jobs := make(chan job)
+	order := make(chan chan *frame, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				j.res <- l.handle(j.b)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for res := range order {
+			select {
+			case f := <-res:
+				l.deliver(ctx, f)
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	handle = func(b []byte) {
+		res := make(chan *frame, 1)
+		select {
+		case order <- res:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case jobs <- job{b: b, res: res}:
+		case <-ctx.Done():
+		}
+	}
+	wait = func() {
+		close(jobs)
+		close(order)
+		wg.Wait()
+		<-done
+	}
+	return handle, wait
+}
+
+// deliver 依次投递一帧中的消息，重连后进房成功的帧会先投递 MsgReconnect
+func (l *Live) deliver(ctx context.Context, f *frame) {
+	if f.entered {
+		select {
+		case l.entered <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		l.mu.Lock()
+		rc := l.rc
+		l.rc = nil
+		l.mu.Unlock()
+		if rc != nil {
+			l.push(ctx, rc, nil)
+		}
+	}
+	for _, t := range f.msgs {
+		l.push(ctx, t.Msg, t.Error)
+	}
+}
+
+// handle 解码一帧，压缩包会拆开后依次解码
+func (l *Live) handle(b []byte) (f *frame) {
+	f = &frame{}
 	defer l.report()
+	l.handleInto(f, b)
+	return f
+}
+func (l *Live) handleInto(f *frame, b []byte) {
 	ver, op, body := decode(b)
 	switch op {
 	case wsOpEnterRoomSuccess:
-		l.info("enter room success: %s", string(body))
-		l.entered <- struct{}{}
+		l.info("enter room success", "reply", string(body))
+		f.entered = true
 	case wsOpHeartbeatReply:
-		l.info("heartbeat reply: %d", binary.BigEndian.Uint32(body))
-		l.push(ctx, &MsgHeartbeatReply{base: base{raw: body}}, nil)
+		l.debug("heartbeat reply", "hot", binary.BigEndian.Uint32(body))
+		f.add(&MsgHeartbeatReply{base: base{raw: body}}, nil)
 	case wsOpMessage:
 		// 压缩版本重新解包再调用，直到 ver==0
 		switch ver {
 		case wsVerZlib:
 			de, err := zlibDe(body)
 			if err != nil {
-				l.push(ctx, nil, fmt.Errorf("failed to decode zlib msg: %s", err))
+				f.add(nil, fmt.Errorf("failed to decode zlib msg: %s", err))
 				return
 			}
-			l.handles(ctx, l.split(de))
+			l.handles(f, l.split(de))
 		case wsVerBrotli:
 			de, err := brotliDe(body)
 			if err != nil {
-				l.push(ctx, nil, fmt.Errorf("failed to decode brotli msg: %s", err))
+				f.add(nil, fmt.Errorf("failed to decode brotli msg: %s", err))
 				return
 			}
-			l.handles(ctx, l.split(de))
+			l.handles(f, l.split(de))
 		case wsVerPlain:
-			l.handlePlain(ctx, body)
+			l.handlePlain(f, body)
 		}
 	}
 }
 
-// split 压缩过的body需要拆包
+// split 压缩过的body需要拆包，长度不合法的剩余部分会被丢弃
 func (l *Live) split(b []byte) [][]byte {
 	var packs [][]byte
-	for i, size := uint32(0), uint32(0); i < uint32(len(b)); i += size {
-		size = binary.BigEndian.Uint32(b[i : i+4])
+	for i, size := uint32(0), uint32(0); i+wsPackageLen <= uint32(len(b)); i += size {
+		size = binary.BigEndian.Uint32(b[i : i+wsPackageLen])
+		if size < wsPackHeaderTotalLen || i+size > uint32(len(b)) {
+			l.warn("invalid pack size", "size", size, "offset", i, "len", len(b))
+			break
+		}
 		packs = append(packs, b[i:i+size])
 	}
 	return packs
 }
-func (l *Live) handles(ctx context.Context, bs [][]byte) {
+func (l *Live) handles(f *frame, bs [][]byte) {
 	for _, b := range bs {
-		go l.handle(ctx, b)
+		l.handleInto(f, b)
 	}
 }
-func (l *Live) handlePlain(ctx context.Context, body []byte) {
+func (l *Live) handlePlain(f *frame, body []byte) {
 	var cmd struct {
 		CMD string `json:"cmd"`
 	}
 	if err := json.Unmarshal(body, &cmd); err != nil {
-		l.push(ctx, nil, fmt.Errorf("failed to unmarshal plain msg: %s", err))
+		f.add(nil, fmt.Errorf("failed to unmarshal plain msg: %s", err))
 		return
 	}
-	m := l.switchCmd(cmd.CMD, body)
-	l.push(ctx, m, nil)
+	f.add(l.switchCmd(cmd.CMD, body), nil)
 }
 func (l *Live) switchCmd(cmd string, body []byte) Msg {
 	var m Msg
@@ -380,36 +751,75 @@ func (l *Live) switchCmd(cmd string, body []byte) Msg {
 	}
 	return m
 }
+
+// push 投递一条消息到 Rev，调用方依次调用即可保证顺序。Rev 来不及读取时按 Backpressure 处理。
+// push 在接收协程中调用，等待 Rev 期间不会读取 websocket
 func (l *Live) push(ctx context.Context, msg Msg, err error) {
-	go func(c context.Context, m Msg, e error) {
-		// 五秒超时
-		after := time.NewTimer(5 * time.Second)
-		defer after.Stop()
+	if msg != nil && !l.accept(msg) {
+		return
+	}
+	t := &Transport{Msg: msg, Error: err}
+	if l.queue != nil {
+		l.queue.put(t)
+		return
+	}
 
-		select {
-		case <-c.Done():
-			l.info("push stopped")
-			return
-		case <-after.C:
-			return
-		case l.Rev <- &Transport{Msg: m, Error: e}:
+	var timeout <-chan time.Time
+	if l.bp.Policy == BackpressureDropNewest {
+		if !l.stalled.IsZero() && time.Now().Before(l.stalled) {
+			// 上一条刚等待超时，冷却期内 Rev 没有空位时直接丢弃，避免每条消息都阻塞接收
+			select {
+			case l.Rev <- t:
+				l.stalled = time.Time{}
+				l.count(func(st *Stats) { st.Delivered++ })
+			default:
+				l.count(func(st *Stats) { st.Dropped++ })
+			}
 			return
 		}
-	}(ctx, msg, err)
-}
-func (l *Live) log(v ...interface{}) {
-	if l.debug {
-		l.logger.Println(v...)
+		l.stalled = time.Time{}
+		after := time.NewTimer(l.bp.Timeout)
+		defer after.Stop()
+		timeout = after.C
+	}
+
+	select {
+	case <-ctx.Done():
+		l.debug("push stopped")
+	case <-timeout:
+		l.stalled = time.Now().Add(l.bp.Timeout)
+		l.count(func(st *Stats) { st.Dropped++ })
+		l.warn("msg dropped, Rev is not drained in time", "timeout", l.bp.Timeout)
+	case l.Rev <- t:
+		l.count(func(st *Stats) { st.Delivered++ })
 	}
 }
-func (l *Live) logf(format string, v ...interface{}) {
-	if l.debug {
-		l.logger.Printf(format, v...)
+
+// fields 附加到每条日志的字段
+func (l *Live) fields(kv []interface{}) []interface{} {
+	l.mu.RLock()
+	host := ""
+	if len(l.hosts) > 0 {
+		host = l.hosts[l.hostIdx]
 	}
+	f := append(make([]interface{}, 0, 6+len(kv)), "room", l.room, "host", host, "attempt", l.attempt)
+	l.mu.RUnlock()
+	return append(f, kv...)
+}
+func (l *Live) setAttempt(attempt int) {
+	l.mu.Lock()
+	l.attempt = attempt
+	l.mu.Unlock()
+}
+func (l *Live) debug(msg string, kv ...interface{}) {
+	l.logger.Debug(msg, l.fields(kv)...)
+}
+func (l *Live) info(msg string, kv ...interface{}) {
+	l.logger.Info(msg, l.fields(kv)...)
 }
-func (l *Live) info(format string, v ...interface{}) {
-	l.logf("[INFO] "+format, v...)
+func (l *Live) warn(msg string, kv ...interface{}) {
+	l.logger.Warn(msg, l.fields(kv)...)
 }
-func (l *Live) error(format string, v ...interface{}) {
-	l.logf("[ERROR] "+format, v)
+func (l *Live) error(msg string, kv ...interface{}) {
+	l.logger.Error(msg, l.fields(kv)...)
 }
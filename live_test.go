@@ -0,0 +1,332 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestServer 模拟弹幕服务器，每个连接收到进房包后回复进房成功，再交给 serve 处理
+func newTestServer(t *testing.T, serve func(n int32, c *websocket.Conn)) (*httptest.Server, string) {
+	return newTestServerWithEnter(t, nil, serve)
+}
+
+// newTestServerWithEnter 同 newTestServer，进房包的 body 会交给 onEnter
+func newTestServerWithEnter(t *testing.T, onEnter func(body []byte), serve func(n int32, c *websocket.Conn)) (*httptest.Server, string) {
+	var n int32
+	up := websocket.Upgrader{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer c.Close()
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		if onEnter != nil {
+			_, _, body := decode(msg)
+			onEnter(body)
+		}
+		if err = c.WriteMessage(websocket.BinaryMessage, encode(wsVerPlain, wsOpEnterRoomSuccess, []byte(`{"code":0}`))); err != nil {
+			return
+		}
+		serve(atomic.AddInt32(&n, 1), c)
+	}))
+	return s, "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func TestReconnect(t *testing.T) {
+	s, host := newTestServer(t, func(n int32, c *websocket.Conn) {
+		// 第一个连接进房后立即断开，之后的连接保持到客户端关闭
+		if n == 1 {
+			return
+		}
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer s.Close()
+
+	l := NewLive(false, time.Second, 10, nil)
+	p := DefaultReconnectPolicy()
+	p.MinDelay = 10 * time.Millisecond
+	p.MaxAttempts = 3
+	l.SetReconnect(p)
+	if err := l.Conn(websocket.DefaultDialer, host); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- l.Enter(ctx, 1, "", 0) }()
+
+	for {
+		select {
+		case tp := <-l.Rev:
+			if rc, ok := tp.Msg.(*MsgReconnect); ok {
+				if rc.Attempt != 1 || rc.Reason == nil {
+					t.Fatalf("unexpected reconnect msg: %+v", rc)
+				}
+				cancel()
+				if err := <-done; err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+		case err := <-done:
+			t.Fatalf("Enter returned before reconnecting: %v", err)
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for MsgReconnect")
+		}
+	}
+}
+
+func TestReconnectOrder(t *testing.T) {
+	msg := func(cmd string) []byte {
+		return encode(wsVerPlain, wsOpMessage, []byte(`{"cmd":"`+cmd+`"}`))
+	}
+	s, host := newTestServer(t, func(n int32, c *websocket.Conn) {
+		if n == 1 {
+			_ = c.WriteMessage(websocket.BinaryMessage, msg("BEFORE"))
+			return
+		}
+		for i := 0; i < 5; i++ {
+			if err := c.WriteMessage(websocket.BinaryMessage, msg("AFTER")); err != nil {
+				return
+			}
+		}
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer s.Close()
+
+	l := NewLive(false, time.Second, 10, nil)
+	p := DefaultReconnectPolicy()
+	p.MinDelay = 10 * time.Millisecond
+	p.MaxAttempts = 3
+	l.SetReconnect(p)
+	if err := l.Conn(websocket.DefaultDialer, host); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- l.Enter(ctx, 1, "", 0) }()
+
+	var got []string
+	for len(got) < 7 {
+		select {
+		case tp := <-l.Rev:
+			// 心跳可能在断开的连接上发送失败
+			if tp.Msg != nil {
+				got = append(got, tp.Msg.Cmd())
+			}
+		case <-ctx.Done():
+			t.Fatalf("timeout, got %v", got)
+		}
+	}
+	cancel()
+	<-done
+	want := []string{"BEFORE", cmdReconnect, "AFTER", "AFTER", "AFTER", "AFTER", "AFTER"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReconnectPolicyDelay(t *testing.T) {
+	p := &ReconnectPolicy{MinDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2}
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := p.delay(i + 1); got != want {
+			t.Errorf("delay(%d) = %s, want %s", i+1, got, want)
+		}
+	}
+}
+
+func TestConnHostsFailover(t *testing.T) {
+	s, host := newTestServer(t, func(n int32, c *websocket.Conn) {})
+	defer s.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadHost := "ws" + strings.TrimPrefix(dead.URL, "http")
+	dead.Close()
+
+	l := NewLive(false, time.Second, 0, nil)
+	if err := l.ConnHosts(websocket.DefaultDialer, []string{deadHost, host}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	defer l.ws.Close()
+	if l.Host() != host {
+		t.Errorf("Host() = %s, want %s", l.Host(), host)
+	}
+	if err := l.ConnHosts(websocket.DefaultDialer, []string{deadHost}, time.Second); err == nil {
+		t.Error("want error when all hosts are unreachable")
+	}
+}
+
+func TestConnContextHandshake(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Cookie") != "SESSDATA=x" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c.Close()
+	}))
+	defer s.Close()
+	host := "ws" + strings.TrimPrefix(s.URL, "http")
+
+	l := NewLive(false, time.Second, 0, nil)
+	err := l.ConnContext(context.Background(), websocket.DefaultDialer, host, nil)
+	var he *HandshakeError
+	if !errors.As(err, &he) || he.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("err = %v, want HandshakeError 412", err)
+	}
+	if l.Response() == nil || l.Response().StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Response() = %v", l.Response())
+	}
+
+	if err = l.ConnContext(context.Background(), websocket.DefaultDialer, host, http.Header{"Cookie": {"SESSDATA=x"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer l.ws.Close()
+	if l.Response().StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("Response().StatusCode = %d", l.Response().StatusCode)
+	}
+}
+
+func TestEnterWithOptions(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	s, host := newTestServerWithEnter(t, func(body []byte) { bodies <- body }, func(n int32, c *websocket.Conn) {
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer s.Close()
+
+	l := NewLive(false, time.Second, 0, nil)
+	if err := l.Conn(websocket.DefaultDialer, host); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- l.EnterWithOptions(ctx, 1, &EnterOptions{
+			Key:   "tok",
+			UID:   2920960,
+			Buvid: "ABCD-1234infoc",
+			Extra: map[string]interface{}{"platform": "android", "foo": "bar"},
+		})
+	}()
+
+	var enter map[string]interface{}
+	if err := json.Unmarshal(<-bodies, &enter); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]interface{}{"key": "tok", "uid": float64(2920960), "buvid": "ABCD-1234infoc", "platform": "android", "foo": "bar"} {
+		if enter[k] != want {
+			t.Errorf("enter[%q] = %v, want %v", k, enter[k], want)
+		}
+	}
+}
+
+// packFrame 将多个 cmd 的明文包拼接后按 ver 压缩，返回服务器下发的完整帧
+func packFrame(t *testing.T, ver uint8, cmds ...string) []byte {
+	var inner []byte
+	for _, cmd := range cmds {
+		inner = append(inner, encode(wsVerPlain, wsOpMessage, []byte(`{"cmd":"`+cmd+`","data":{}}`))...)
+	}
+	var (
+		body []byte
+		err  error
+	)
+	switch ver {
+	case wsVerZlib:
+		body, err = zlibEn(inner)
+	case wsVerBrotli:
+		body, err = brotliEn(inner)
+	default:
+		body = inner
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encode(ver, wsOpMessage, body)
+}
+
+func TestHandleCompressed(t *testing.T) {
+	cmds := []string{cmdDanmaku, cmdSendGift, cmdInteractWord, "UNKNOWN_CMD"}
+	for _, ver := range []uint8{wsVerZlib, wsVerBrotli} {
+		l := NewLive(false, time.Second, 0, nil)
+		f := l.handle(packFrame(t, ver, cmds...))
+
+		var got []string
+		for _, tp := range f.msgs {
+			if tp.Error != nil {
+				t.Fatalf("ver %d: %s", ver, tp.Error)
+			}
+			got = append(got, tp.Msg.Cmd())
+		}
+		if strings.Join(got, ",") != strings.Join(cmds, ",") {
+			t.Errorf("ver %d: got %v, want %v", ver, got, cmds)
+		}
+	}
+}
+
+func TestParallelOrder(t *testing.T) {
+	const frames = 200
+	l := NewLive(false, time.Second, frames*2, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	handle, wait := l.parallel(ctx, 8)
+	for i := 0; i < frames; i++ {
+		handle(packFrame(t, wsVerBrotli, fmt.Sprintf("C%d_0", i), fmt.Sprintf("C%d_1", i)))
+	}
+	wait()
+
+	for i := 0; i < frames; i++ {
+		for j := 0; j < 2; j++ {
+			tp := <-l.Rev
+			if want := fmt.Sprintf("C%d_%d", i, j); tp.Msg.Cmd() != want {
+				t.Fatalf("got %s, want %s", tp.Msg.Cmd(), want)
+			}
+		}
+	}
+}
+
+func TestSetProtover(t *testing.T) {
+	l := NewLive(false, time.Second, 0, nil)
+	if err := l.SetProtover(ProtoverBrotli); err != nil || l.protover != ProtoverBrotli {
+		t.Errorf("SetProtover(3) = %v, protover = %d", err, l.protover)
+	}
+	if err := l.SetProtover(1); err == nil {
+		t.Error("want error for protover 1")
+	}
+}
@@ -0,0 +1,50 @@
+package live
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger 日志接口，kv 为交替出现的键值对。Live 输出的日志会附带 room、host、attempt 字段
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+// NewStdLogger 将 *log.Logger 包装为 Logger，输出形如 [INFO] msg k=v。
+// 设置了 log.Lshortfile 等标志时，输出的是调用 Logger 方法的位置
+func NewStdLogger(l *log.Logger) Logger {
+	return &stdLogger{l: l, depth: 3}
+}
+
+type stdLogger struct {
+	l     *log.Logger
+	depth int // 传给 log.Logger.Output 的调用深度
+}
+
+func (s *stdLogger) Debug(msg string, kv ...interface{}) { s.print("DEBUG", msg, kv) }
+func (s *stdLogger) Info(msg string, kv ...interface{})  { s.print("INFO", msg, kv) }
+func (s *stdLogger) Warn(msg string, kv ...interface{})  { s.print("WARN", msg, kv) }
+func (s *stdLogger) Error(msg string, kv ...interface{}) { s.print("ERROR", msg, kv) }
+func (s *stdLogger) print(level, msg string, kv []interface{}) {
+	var b strings.Builder
+	b.WriteString("[" + level + "] " + msg)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 < len(kv) {
+			fmt.Fprintf(&b, " %v=%v", kv[i], kv[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v", kv[i])
+		}
+	}
+	_ = s.l.Output(s.depth, b.String())
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
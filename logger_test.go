@@ -0,0 +1,29 @@
+package live
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	NewStdLogger(log.New(&buf, "", 0)).Warn("msg dropped", "room", 1, "odd")
+	if got := strings.TrimSpace(buf.String()); got != "[WARN] msg dropped room=1 odd" {
+		t.Errorf("got %q", got)
+	}
+
+	buf.Reset()
+	NewStdLogger(log.New(&buf, "", log.Lshortfile)).Info("hi")
+	if got := buf.String(); !strings.HasPrefix(got, "logger_test.go:") {
+		t.Errorf("got %q, want the caller's file", got)
+	}
+
+	buf.Reset()
+	l := &Live{logger: &stdLogger{l: log.New(&buf, "", log.Lshortfile), depth: 4}}
+	l.info("hi")
+	if got := buf.String(); !strings.HasPrefix(got, "logger_test.go:") {
+		t.Errorf("got %q, want the caller's file", got)
+	}
+}
@@ -0,0 +1,325 @@
+package live
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LotteryPhase int
+
+const (
+	LotteryChecking LotteryPhase = iota + 1 // 审核中，ANCHOR_LOT_CHECKSTATUS
+	LotteryRunning                          // 倒计时中，ANCHOR_LOT_START
+	LotteryEnded                            // 倒计时结束，等待开奖，ANCHOR_LOT_END
+	LotteryAwarded                          // 已开奖，ANCHOR_LOT_AWARD
+)
+
+func (p LotteryPhase) String() string {
+	switch p {
+	case LotteryChecking:
+		return "checking"
+	case LotteryRunning:
+		return "running"
+	case LotteryEnded:
+		return "ended"
+	case LotteryAwarded:
+		return "awarded"
+	}
+	return "unknown"
+}
+
+// 天选时刻的参与条件，对应 require_type
+const (
+	LotteryRequireNone   = 0
+	LotteryRequireFollow = 1 // 关注主播
+	LotteryRequireMedal  = 2 // 粉丝勋章，require_value 为等级
+	LotteryRequireGuard  = 3 // 大航海，require_value 为等级
+)
+
+// LotteryWinner 天选时刻的中奖用户
+type LotteryWinner struct {
+	UID          int64
+	Uname        string
+	Face         string
+	Level        int
+	Participated bool // 是否在倒计时期间发送过口令弹幕
+}
+
+// Lottery 一次天选时刻
+type Lottery struct {
+	ID        int64
+	RoomID    int64
+	Phase     LotteryPhase
+	AwardName string
+	AwardNum  int
+
+	Keyword      string // 需要发送的口令弹幕，空为不需要
+	GiftID       int64  // 需要投喂的礼物，0 为不需要
+	GiftName     string
+	GiftNum      int
+	GiftPrice    int
+	RequireType  int // 参见 LotteryRequireFollow
+	RequireValue int
+	RequireText  string
+
+	CheckStatus  int // ANCHOR_LOT_CHECKSTATUS 中的审核状态
+	RejectReason string
+
+	StartTime int64 // unix 秒，收到 ANCHOR_LOT_START 时的服务器时间
+	EndTime   int64 // unix 秒，倒计时结束
+
+	Participants []int64 // 倒计时期间发送过口令弹幕的用户，按首次发送排序
+	Winners      []LotteryWinner
+}
+
+// RequireFollow 是否需要关注主播
+func (l *Lottery) RequireFollow() bool {
+	return l.RequireType == LotteryRequireFollow
+}
+
+// Remaining 距离倒计时结束的时间
+func (l *Lottery) Remaining(now time.Time) time.Duration {
+	if l.Phase != LotteryRunning || l.EndTime == 0 {
+		return 0
+	}
+	if d := time.Unix(l.EndTime, 0).Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// lotteryKeep LotteryTracker 最多保留的天选时刻个数
+const lotteryKeep = 100
+
+type lotteryState struct {
+	l      *Lottery
+	joined map[int64]bool
+}
+
+// LotteryTracker 按 id 关联天选时刻的审核、开始、结束和开奖，
+// 并统计倒计时期间发送口令弹幕的用户。只保留最近的 100 个天选时刻，更早的会被移除
+type LotteryTracker struct {
+	mu        sync.Mutex
+	lotteries map[int64]*lotteryState
+	order     []int64
+	onUpdate  func(*Lottery)
+	onAward   func(*Lottery)
+}
+
+func NewLotteryTracker() *LotteryTracker {
+	return &LotteryTracker{lotteries: make(map[int64]*lotteryState)}
+}
+
+// OnUpdate 天选时刻的阶段变化，参数为副本
+func (t *LotteryTracker) OnUpdate(f func(*Lottery)) {
+	t.mu.Lock()
+	t.onUpdate = f
+	t.mu.Unlock()
+}
+
+// OnAward 开奖，参数为副本，其中 Winners 为中奖用户
+func (t *LotteryTracker) OnAward(f func(*Lottery)) {
+	t.mu.Lock()
+	t.onAward = f
+	t.mu.Unlock()
+}
+
+// Register 注册天选时刻相关 cmd，弹幕只在有口令的天选进行中解析
+func (t *LotteryTracker) Register(d *Dispatcher) {
+	for _, cmd := range []string{cmdAnchorLotCheckStatus, cmdAnchorLotStart, cmdAnchorLotEnd, cmdAnchorLotAward, cmdDanmaku} {
+		d.on(cmd, t.Handle)
+	}
+}
+
+// Handle 处理一条消息，无关的消息会被忽略
+func (t *LotteryTracker) Handle(m Msg) error {
+	var (
+		l     *Lottery
+		award bool
+		err   error
+	)
+	switch m := m.(type) {
+	case *MsgAnchorLotCheckStatus:
+		var c *AnchorLotCheckStatus
+		if c, err = m.Parse(); err == nil {
+			l = t.update(c.ID, func(l *Lottery) {
+				if l.Phase == 0 {
+					l.Phase = LotteryChecking
+				}
+				l.CheckStatus, l.RejectReason = c.Status, c.RejectReason
+			})
+		}
+	case *MsgAnchorLotStart:
+		var s *AnchorLotStart
+		if s, err = m.Parse(); err == nil {
+			l = t.update(s.ID, func(l *Lottery) {
+				l.Phase = LotteryRunning
+				l.RoomID, l.AwardName, l.AwardNum = s.RoomID, s.AwardName, s.AwardNum
+				l.Keyword = strings.TrimSpace(s.Danmu)
+				l.GiftID, l.GiftName, l.GiftNum, l.GiftPrice = s.GiftID, s.GiftName, s.GiftNum, s.GiftPrice
+				l.RequireType, l.RequireValue, l.RequireText = s.RequireType, s.RequireValue, s.RequireText
+				l.StartTime, l.EndTime = s.CurrentTime, s.CurrentTime+s.Time
+			})
+		}
+	case *MsgAnchorLotEnd:
+		id := m.GetID()
+		if id < 0 {
+			err = fmt.Errorf("invalid data")
+			break
+		}
+		l = t.update(id, func(l *Lottery) {
+			if l.Phase != LotteryAwarded {
+				l.Phase = LotteryEnded
+			}
+		})
+	case *MsgAnchorLotAward:
+		var a *AnchorLotAward
+		if a, err = m.Parse(); err == nil {
+			award = true
+			l = t.award(a)
+		}
+	case *MsgDanmaku:
+		// 弹幕很多，只在有口令的天选倒计时期间解析。解析失败由 OnDanmaku 报告，这里不再重复
+		if !t.running() {
+			return nil
+		}
+		if dm, err := m.Parse(); err == nil {
+			t.danmaku(dm)
+		}
+		return nil
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", m.Cmd(), err)
+	}
+
+	t.mu.Lock()
+	onUpdate, onAward := t.onUpdate, t.onAward
+	t.mu.Unlock()
+	if onUpdate != nil {
+		onUpdate(l)
+	}
+	if award && onAward != nil {
+		onAward(l)
+	}
+	return nil
+}
+
+// update 修改 id 对应的天选时刻，返回修改后的副本
+func (t *LotteryTracker) update(id int64, f func(*Lottery)) *Lottery {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.state(id)
+	f(s.l)
+	return s.copy()
+}
+
+func (t *LotteryTracker) award(a *AnchorLotAward) *Lottery {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.state(a.ID)
+	s.l.Phase = LotteryAwarded
+	if a.AwardName != "" {
+		s.l.AwardName, s.l.AwardNum = a.AwardName, a.AwardNum
+	}
+	s.l.Winners = s.l.Winners[:0]
+	for _, u := range a.AwardUsers {
+		s.l.Winners = append(s.l.Winners, LotteryWinner{
+			UID: u.UID, Uname: u.Uname, Face: u.Face, Level: u.Level, Participated: s.joined[u.UID],
+		})
+	}
+	return s.copy()
+}
+
+// running 是否有需要口令的天选时刻正在倒计时
+func (t *LotteryTracker) running() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.lotteries {
+		if s.l.Phase == LotteryRunning && s.l.Keyword != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// danmaku 倒计时期间发送口令的用户计为参与。
+// 以弹幕的发送时间为准，没有收到 ANCHOR_LOT_END 时倒计时结束后的弹幕也不计入
+func (t *LotteryTracker) danmaku(dm *Danmaku) {
+	content := strings.TrimSpace(dm.Content)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.lotteries {
+		if s.l.Phase != LotteryRunning || s.l.Keyword == "" || s.l.Keyword != content || s.joined[dm.MID] {
+			continue
+		}
+		if s.l.EndTime != 0 && dm.Time/1000 >= s.l.EndTime {
+			continue
+		}
+		s.joined[dm.MID] = true
+		s.l.Participants = append(s.l.Participants, dm.MID)
+	}
+}
+
+func (t *LotteryTracker) state(id int64) *lotteryState {
+	s, ok := t.lotteries[id]
+	if !ok {
+		s = &lotteryState{l: &Lottery{ID: id}, joined: make(map[int64]bool)}
+		t.lotteries[id] = s
+		t.order = append(t.order, id)
+		for len(t.order) > lotteryKeep {
+			delete(t.lotteries, t.order[0])
+			t.order[0] = 0
+			t.order = t.order[1:]
+		}
+	}
+	return s
+}
+
+func (s *lotteryState) copy() *Lottery {
+	l := *s.l
+	l.Participants = append([]int64(nil), s.l.Participants...)
+	l.Winners = append([]LotteryWinner(nil), s.l.Winners...)
+	return &l
+}
+
+// Lottery id 对应的天选时刻的副本
+func (t *LotteryTracker) Lottery(id int64) (*Lottery, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.lotteries[id]
+	if !ok {
+		return nil, false
+	}
+	return s.copy(), true
+}
+
+// Lotteries 所有天选时刻的副本，按首次收到的顺序排列
+func (t *LotteryTracker) Lotteries() []*Lottery {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := make([]*Lottery, 0, len(t.order))
+	for _, id := range t.order {
+		r = append(r, t.lotteries[id].copy())
+	}
+	return r
+}
+
+// Remove 移除已经不需要的天选时刻
+func (t *LotteryTracker) Remove(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.lotteries[id]; !ok {
+		return
+	}
+	delete(t.lotteries, id)
+	for i := range t.order {
+		if t.order[i] == id {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			break
+		}
+	}
+}
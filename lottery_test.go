@@ -0,0 +1,101 @@
+package live
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLotteryTracker(t *testing.T) {
+	danmaku := func(uid int64, content string) Msg {
+		return cmdMsg(cmdDanmaku, fmt.Sprintf(`{"cmd":"DANMU_MSG","info":[[0,1,25,16777215,1651401000000,0,0,"",0,0,0,"",0,"{}","{}",{}],%q,[%d,"u%d",0,0,0,10000,1,""],[]]}`, content, uid, uid))
+	}
+
+	tr := NewLotteryTracker()
+	var (
+		phases []string
+		award  *Lottery
+	)
+	tr.OnUpdate(func(l *Lottery) { phases = append(phases, l.Phase.String()) })
+	tr.OnAward(func(l *Lottery) { award = l })
+	d := newTrackerDispatcher(t, tr)
+
+	// 开始前发送的口令不计入
+	d.DispatchMsg(danmaku(1, "老番茄yyds"))
+	d.DispatchMsg(cmdMsg(cmdAnchorLotCheckStatus, `{"cmd":"ANCHOR_LOT_CHECKSTATUS","data":{"id":1890708,"reject_reason":"","status":4,"uid":546195}}`))
+	d.DispatchMsg(cmdMsg(cmdAnchorLotStart, `{"cmd":"ANCHOR_LOT_START","data":{"id":1890708,"room_id":21852,"award_name":"手办","award_num":1,"danmu":"老番茄yyds","gift_id":0,"gift_num":1,"require_type":1,"require_value":0,"require_text":"关注主播","current_time":1651401000,"time":600,"max_time":600,"lot_status":0,"status":1}}`))
+
+	lot, ok := tr.Lottery(1890708)
+	if !ok || lot.Phase != LotteryRunning || lot.Keyword != "老番茄yyds" || !lot.RequireFollow() || lot.CheckStatus != 4 || lot.EndTime != 1651401600 {
+		t.Fatalf("lottery = %+v", lot)
+	}
+	if r := lot.Remaining(time.Unix(1651401300, 0)); r != 5*time.Minute {
+		t.Errorf("Remaining = %s, want 5m", r)
+	}
+
+	d.DispatchMsg(danmaku(2, "老番茄yyds"))
+	d.DispatchMsg(danmaku(3, "其他弹幕"))
+	// 弹幕解析失败由 OnDanmaku 报告，tracker 不重复报告
+	d.DispatchMsg(cmdMsg(cmdDanmaku, `{"cmd":"DANMU_MSG","info":1}`))
+	d.DispatchMsg(danmaku(1, " 老番茄yyds "))
+	d.DispatchMsg(danmaku(2, "老番茄yyds"))
+	d.DispatchMsg(cmdMsg(cmdAnchorLotEnd, `{"cmd":"ANCHOR_LOT_END","data":{"id":1890708}}`))
+	// 结束后发送的口令不计入
+	d.DispatchMsg(danmaku(4, "老番茄yyds"))
+	d.DispatchMsg(cmdMsg(cmdAnchorLotAward, `{"cmd":"ANCHOR_LOT_AWARD","data":{"id":1890708,"award_name":"手办","award_num":1,"award_users":[{"uid":2,"uname":"u2","face":"","level":20,"color":0},{"uid":4,"uname":"u4","face":"","level":1,"color":0}],"lot_status":2}}`))
+
+	if award == nil {
+		t.Fatal("OnAward not called")
+	}
+	if len(award.Participants) != 2 || award.Participants[0] != 2 || award.Participants[1] != 1 {
+		t.Errorf("Participants = %v", award.Participants)
+	}
+	if len(award.Winners) != 2 || !award.Winners[0].Participated || award.Winners[1].Participated {
+		t.Errorf("Winners = %+v", award.Winners)
+	}
+	if got := strings.Join(phases, ","); got != "checking,running,ended,awarded" {
+		t.Errorf("phases = %v", phases)
+	}
+
+	tr.Remove(1890708)
+	if len(tr.Lotteries()) != 0 {
+		t.Errorf("Lotteries() = %v after Remove", tr.Lotteries())
+	}
+}
+
+func TestLotteryTrackerLateDanmaku(t *testing.T) {
+	danmaku := func(uid int64, ts int64) Msg {
+		return cmdMsg(cmdDanmaku, fmt.Sprintf(`{"cmd":"DANMU_MSG","info":[[0,1,25,16777215,%d,0,0,"",0,0,0,"",0,"{}","{}",{}],"口令",[%d,"u%d",0,0,0,10000,1,""],[]]}`, ts, uid, uid))
+	}
+	tr := NewLotteryTracker()
+	// ANCHOR_LOT_END 丢失时，倒计时结束后的口令也不计入
+	for _, m := range []Msg{
+		cmdMsg(cmdAnchorLotStart, `{"cmd":"ANCHOR_LOT_START","data":{"id":1,"danmu":"口令","current_time":1651401000,"time":600}}`),
+		danmaku(1, 1651401599999),
+		danmaku(2, 1651401600000),
+		danmaku(3, 1651402000000),
+	} {
+		if err := tr.Handle(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lot, _ := tr.Lottery(1); len(lot.Participants) != 1 || lot.Participants[0] != 1 {
+		t.Errorf("Participants = %v", lot.Participants)
+	}
+}
+
+func TestLotteryTrackerKeep(t *testing.T) {
+	tr := NewLotteryTracker()
+	for id := 1; id <= lotteryKeep+1; id++ {
+		if err := tr.Handle(cmdMsg(cmdAnchorLotEnd, fmt.Sprintf(`{"cmd":"ANCHOR_LOT_END","data":{"id":%d}}`, id))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := tr.Lottery(1); ok {
+		t.Error("oldest lottery not removed")
+	}
+	if l := tr.Lotteries(); len(l) != lotteryKeep || l[0].ID != 2 {
+		t.Errorf("Lotteries() = %d, first %d", len(l), l[0].ID)
+	}
+}
@@ -3,6 +3,8 @@ package live
 import (
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"strings"
 )
 
 // TODO msg注释移到struct上
@@ -30,6 +32,55 @@ func getData(raw []byte) json.RawMessage {
 	return d.Data
 }
 
+// jsonArray 按下标解码的 json 数组，如 DANMU_MSG 的 info
+type jsonArray []json.RawMessage
+
+// namedArray 带路径名的 jsonArray，用于生成指明下标的错误
+type namedArray struct {
+	name string
+	a    jsonArray
+}
+
+func (a jsonArray) named(name string) namedArray {
+	return namedArray{name: name, a: a}
+}
+
+// at 解码第 i 个元素，缺失或类型不符时返回指明下标的错误
+func (n namedArray) at(i int, v interface{}) error {
+	if i >= len(n.a) {
+		return fmt.Errorf("missing %s[%d] (len %d)", n.name, i, len(n.a))
+	}
+	if err := json.Unmarshal(n.a[i], v); err != nil {
+		return fmt.Errorf("invalid %s[%d]: %s", n.name, i, err)
+	}
+	return nil
+}
+
+// optional 同 at，但元素缺失时不报错
+func (n namedArray) optional(i int, v interface{}) error {
+	if i >= len(n.a) {
+		return nil
+	}
+	return n.at(i, v)
+}
+
+func (n namedArray) field(i int, v interface{}) func() error {
+	return func() error { return n.at(i, v) }
+}
+func (n namedArray) optionalField(i int, v interface{}) func() error {
+	return func() error { return n.optional(i, v) }
+}
+
+// all 依次执行，返回第一个错误
+func (n namedArray) all(fs ...func() error) error {
+	for _, f := range fs {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 //
 
 type MsgGeneral struct {
@@ -56,7 +107,7 @@ type MsgHeartbeatReply struct {
 }
 
 func (m *MsgHeartbeatReply) Cmd() string {
-	return "HEARTBEAT_REPLY"
+	return cmdHeartbeatReply
 }
 func (m *MsgHeartbeatReply) Raw() []byte {
 	return m.raw
@@ -67,6 +118,23 @@ func (m *MsgHeartbeatReply) GetHot() int {
 
 //
 
+// MsgReconnect 断线重连成功并重新进入房间，断线期间的消息可能已丢失
+type MsgReconnect struct {
+	base
+	Attempt int    // 本次重连是第几次尝试
+	Reason  error  // 导致断线的错误
+	Host    string // 重连后使用的 host
+}
+
+func (m *MsgReconnect) Cmd() string {
+	return cmdReconnect
+}
+func (m *MsgReconnect) Raw() []byte {
+	return m.raw
+}
+
+//
+
 // MsgDanmaku 弹幕消息
 type MsgDanmaku struct {
 	base
@@ -100,60 +168,220 @@ type Danmaku struct {
 	UpName       string `json:"up_name"`
 	MedalLevel   int    `json:"medal_level"`
 	UserLevel    int    `json:"user_level"`
-}
 
+	Emoticon   *DanmakuEmoticon        `json:"emoticon"`    // 表情包弹幕，info[0][13]，普通弹幕为 nil
+	Emots      map[string]*DanmakuEmot `json:"emots"`       // 弹幕中的内联表情，key 为文本中的 [xxx]
+	Reply      *DanmakuReply           `json:"reply"`       // 回复(@)的用户，没有时为 nil
+	Medal      *DanmakuMedal           `json:"medal"`       // 粉丝勋章，info[3]，未佩戴时为 nil
+	Title      string                  `json:"title"`       // 用户头衔，info[5]
+	GuardLevel int                     `json:"guard_level"` // 大航海等级，info[7]。0:无 1:总督 2:提督 3:舰长
+}
+
+// DanmakuEmoticon 表情包弹幕
+type DanmakuEmoticon struct {
+	EmoticonUnique string `json:"emoticon_unique"`
+	URL            string `json:"url"`
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	IsDynamic      int    `json:"is_dynamic"`
+	InPlayerArea   int    `json:"in_player_area"`
+	BulgeDisplay   int    `json:"bulge_display"`
+}
+
+// DanmakuEmot 弹幕中的内联表情
+type DanmakuEmot struct {
+	EmoticonID     int64  `json:"emoticon_id"`
+	EmoticonUnique string `json:"emoticon_unique"`
+	Emoji          string `json:"emoji"`
+	Descript       string `json:"descript"`
+	URL            string `json:"url"`
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	Count          int    `json:"count"`
+}
+
+// DanmakuReply 弹幕回复的用户
+type DanmakuReply struct {
+	MID        int64  `json:"reply_mid"`
+	Uname      string `json:"reply_uname"`
+	UnameColor string `json:"reply_uname_color"`
+	IsMystery  bool   `json:"reply_is_mystery"`
+}
+
+// DanmakuMedal 弹幕发送者佩戴的粉丝勋章
+type DanmakuMedal struct {
+	Level       int    `json:"level"`
+	Name        string `json:"name"`
+	UpName      string `json:"up_name"`
+	RoomID      int64  `json:"room_id"`      // 勋章所属直播间
+	Color       int64  `json:"color"`        // 勋章颜色
+	Special     string `json:"special"`      //
+	IconID      int64  `json:"icon_id"`      //
+	ColorBorder int64  `json:"color_border"` // 边框颜色
+	ColorStart  int64  `json:"color_start"`  // 渐变起始颜色
+	ColorEnd    int64  `json:"color_end"`    // 渐变结束颜色
+	GuardLevel  int    `json:"guard_level"`  // 在勋章所属直播间的大航海等级
+	IsLighted   int    `json:"is_lighted"`   // 勋章是否点亮
+	UpUID       int64  `json:"up_uid"`       // 勋章所属主播UID
+}
+
+// Parse 解析 info 数组，缺失或类型不符的下标会在错误中指明，如 missing info[0][5]
 func (m *MsgDanmaku) Parse() (*Danmaku, error) {
-	var t map[string]interface{}
+	dm, err := m.parse()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", cmdDanmaku, err)
+	}
+	return dm, nil
+}
+func (m *MsgDanmaku) parse() (*Danmaku, error) {
+	var t struct {
+		Info jsonArray `json:"info"`
+	}
 	if err := json.Unmarshal(m.raw, &t); err != nil {
 		return nil, err
 	}
-	info := t["info"]
+	info := t.Info.named("info")
 	var dm = &Danmaku{}
-	l := len(info.([]interface{}))
-	if l >= 1 {
-		h := info.([]interface{})[0].([]interface{})
-		dm.SendMode = int(h[1].(float64))
-		dm.SendFontSize = int(h[2].(float64))
-		dm.DanmakuColor = int64(h[3].(float64))
-		dm.Time = int64(h[4].(float64))
-		dm.DMID = int64(h[5].(float64))
-		dm.MsgType = int(h[10].(float64))
-		dm.Bubble = h[11].(string)
-	}
-	if l >= 2 {
-		dm.Content = info.([]interface{})[1].(string)
-	}
-	if l >= 3 {
-		h := info.([]interface{})[2].([]interface{})
-		dm.MID = int64(h[0].(float64))
-		dm.Uname = h[1].(string)
-		dm.RoomAdmin = int(h[2].(float64))
-		dm.Vip = int(h[3].(float64))
-		dm.SVip = int(h[4].(float64))
-		dm.Rank = int(h[5].(float64))
-		dm.MobileVerify = int(h[6].(float64))
-		dm.UnameColor = h[7].(string)
-	}
-	if l >= 4 {
-		h := info.([]interface{})[3].([]interface{})
-		l2 := len(h)
-		if l2 >= 1 {
-			dm.MedalLevel = int(h[0].(float64))
-		}
-		if l2 >= 2 {
-			dm.MedalName = h[1].(string)
-		}
-		if l2 >= 3 {
-			dm.UpName = h[2].(string)
+
+	// info[0] 弹幕属性
+	var h jsonArray
+	if err := info.at(0, &h); err != nil {
+		return nil, err
+	}
+	h0 := h.named("info[0]")
+	if err := h0.all(
+		h0.field(1, &dm.SendMode),
+		h0.field(2, &dm.SendFontSize),
+		h0.field(3, &dm.DanmakuColor),
+		h0.field(4, &dm.Time),
+		h0.field(5, &dm.DMID),
+		h0.field(10, &dm.MsgType),
+		h0.field(11, &dm.Bubble),
+	); err != nil {
+		return nil, err
+	}
+	if err := parseDanmakuExtra(dm, h0); err != nil {
+		return nil, err
+	}
+
+	// info[1] 弹幕内容
+	if err := info.at(1, &dm.Content); err != nil {
+		return nil, err
+	}
+
+	// info[2] 用户信息
+	if err := info.at(2, &h); err != nil {
+		return nil, err
+	}
+	h2 := h.named("info[2]")
+	if err := h2.all(
+		h2.field(0, &dm.MID),
+		h2.field(1, &dm.Uname),
+		h2.field(2, &dm.RoomAdmin),
+		h2.field(3, &dm.Vip),
+		h2.field(4, &dm.SVip),
+		h2.field(5, &dm.Rank),
+		h2.field(6, &dm.MobileVerify),
+		h2.field(7, &dm.UnameColor),
+	); err != nil {
+		return nil, err
+	}
+
+	// info[3] 粉丝勋章，没有佩戴时为空数组
+	h = nil
+	if err := info.optional(3, &h); err != nil {
+		return nil, err
+	}
+	h3 := h.named("info[3]")
+	if len(h) > 0 {
+		md := &DanmakuMedal{}
+		if err := h3.all(
+			h3.field(0, &md.Level),
+			h3.optionalField(1, &md.Name),
+			h3.optionalField(2, &md.UpName),
+			h3.optionalField(3, &md.RoomID),
+			h3.optionalField(4, &md.Color),
+			h3.optionalField(5, &md.Special),
+			h3.optionalField(6, &md.IconID),
+			h3.optionalField(7, &md.ColorBorder),
+			h3.optionalField(8, &md.ColorStart),
+			h3.optionalField(9, &md.ColorEnd),
+			h3.optionalField(10, &md.GuardLevel),
+			h3.optionalField(11, &md.IsLighted),
+			h3.optionalField(12, &md.UpUID),
+		); err != nil {
+			return nil, err
 		}
+		dm.Medal = md
+		dm.MedalLevel, dm.MedalName, dm.UpName = md.Level, md.Name, md.UpName
 	}
-	if l >= 5 {
-		dm.UserLevel = int(info.([]interface{})[4].([]interface{})[0].(float64))
+
+	// info[4] 用户等级
+	h = nil
+	if err := info.optional(4, &h); err != nil {
+		return nil, err
+	}
+	if err := h.named("info[4]").optional(0, &dm.UserLevel); err != nil {
+		return nil, err
+	}
+
+	// info[5] 头衔 [旧头衔, 头衔]
+	var titles []string
+	if err := info.optional(5, &titles); err != nil {
+		return nil, err
+	}
+	if len(titles) >= 2 {
+		dm.Title = titles[1]
+	} else if len(titles) == 1 {
+		dm.Title = titles[0]
+	}
+
+	// info[7] 大航海等级
+	if err := info.optional(7, &dm.GuardLevel); err != nil {
+		return nil, err
 	}
 	return dm, nil
 }
 
-//
+// parseDanmakuExtra 解析 info[0] 中的表情包(13)与 extra(15) 中的内联表情、回复对象
+func parseDanmakuExtra(dm *Danmaku, h0 namedArray) error {
+	// 非表情包弹幕时为字符串 "{}"
+	var emoticon json.RawMessage
+	if err := h0.optional(13, &emoticon); err != nil {
+		return err
+	}
+	if len(emoticon) > 0 && emoticon[0] == '{' {
+		e := &DanmakuEmoticon{}
+		if err := h0.at(13, e); err != nil {
+			return err
+		}
+		if e.EmoticonUnique != "" || e.URL != "" {
+			dm.Emoticon = e
+		}
+	}
+
+	var ext struct {
+		Extra string `json:"extra"`
+	}
+	if err := h0.optional(15, &ext); err != nil {
+		return err
+	}
+	if ext.Extra == "" {
+		return nil
+	}
+	var extra struct {
+		Emots map[string]*DanmakuEmot `json:"emots"`
+		DanmakuReply
+	}
+	if err := json.Unmarshal([]byte(ext.Extra), &extra); err != nil {
+		return fmt.Errorf("invalid %s[15].extra: %s", h0.name, err)
+	}
+	dm.Emots = extra.Emots
+	if extra.MID != 0 || extra.Uname != "" {
+		dm.Reply = &extra.DanmakuReply
+	}
+	return nil
+}
 
 // MsgSendGift 投喂礼物
 type MsgSendGift struct {
@@ -277,6 +505,54 @@ func (m *MsgComboSend) Raw() []byte {
 	return m.raw
 }
 
+type ComboSend struct {
+	Action         string `json:"action"`
+	BatchComboID   string `json:"batch_combo_id"` // 与 SendGift.BatchComboID 相同
+	BatchComboNum  int    `json:"batch_combo_num"`
+	ComboID        string `json:"combo_id"`
+	ComboNum       int    `json:"combo_num"`        // 连击次数
+	ComboTotalCoin int    `json:"combo_total_coin"` // 连击的总价值，单位同 SendGift.TotalCoin
+	Dmscore        int    `json:"dmscore"`
+	GiftID         int64  `json:"gift_id"`
+	GiftName       string `json:"gift_name"`
+	GiftNum        int    `json:"gift_num"`
+	IsShow         int    `json:"is_show"`
+	MedalInfo      struct {
+		AnchorRoomid int    `json:"anchor_roomid"`
+		AnchorUname  string `json:"anchor_uname"`
+		GuardLevel   int    `json:"guard_level"`
+		IsLighted    int    `json:"is_lighted"`
+		MedalColor   int    `json:"medal_color"`
+		MedalLevel   int    `json:"medal_level"`
+		MedalName    string `json:"medal_name"`
+		TargetID     int    `json:"target_id"`
+	} `json:"medal_info"`
+	NameColor       string `json:"name_color"`
+	RUname          string `json:"r_uname"`
+	ReceiveUserInfo struct {
+		UID   int64  `json:"uid"`
+		Uname string `json:"uname"`
+	} `json:"receive_user_info"`
+	RUID       int64       `json:"ruid"`
+	SendMaster interface{} `json:"send_master"`
+	TotalNum   int         `json:"total_num"` // 连击送出的礼物总数
+	UID        int64       `json:"uid"`
+	Uname      string      `json:"uname"`
+}
+
+// Matches 判断 g 是否属于这次连击，通过 batch_combo_id 关联
+func (c *ComboSend) Matches(g *SendGift) bool {
+	return c.BatchComboID != "" && c.BatchComboID == g.BatchComboID
+}
+
+func (m *MsgComboSend) Parse() (*ComboSend, error) {
+	var r = &ComboSend{}
+	if err := json.Unmarshal(getData(m.raw), &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 // MsgFansUpdate 粉丝数量改变
@@ -439,6 +715,7 @@ type HotRankSettlement struct {
 	Uname     string `json:"uname"`
 	Url       string `json:"url"`
 	AreaName  string `json:"area_name"`
+	RankType  string `json:"rank_type"`
 	CacheKey  string `json:"cache_key"`
 	Rank      int    `json:"rank"`
 	Face      string `json:"face"`
@@ -658,6 +935,8 @@ type HotRankChanged struct {
 	LiveUrl     string `json:"live_url"`
 	LiveLinkUrl string `json:"live_link_url"`
 	AreaName    string `json:"area_name"`
+	RankType    string `json:"rank_type"`
+	RankDesc    string `json:"rank_desc"`
 	Trend       int    `json:"trend"`
 	Countdown   int    `json:"countdown"`
 	BlinkUrl    string `json:"blink_url"`
@@ -1158,6 +1437,27 @@ func (m *MsgPreparing) Raw() []byte {
 	return m.raw
 }
 
+// Preparing 字段在顶层，roomid 为字符串
+type Preparing struct {
+	RoomID int64
+	Round  int // 1:下播后开始轮播
+}
+
+func (m *MsgPreparing) Parse() (*Preparing, error) {
+	var r struct {
+		RoomID json.RawMessage `json:"roomid"`
+		Round  int             `json:"round"`
+	}
+	if err := json.Unmarshal(m.raw, &r); err != nil {
+		return nil, err
+	}
+	id, err := parseFlexInt(r.RoomID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid roomid: %s", err)
+	}
+	return &Preparing{RoomID: id, Round: r.Round}, nil
+}
+
 //
 
 // MsgLive 开播
@@ -1172,6 +1472,38 @@ func (m *MsgLive) Raw() []byte {
 	return m.raw
 }
 
+// LiveStart 字段在顶层。开播时会连续下发多个 LIVE，部分只有 roomid 且为字符串
+type LiveStart struct {
+	RoomID        int64
+	LiveKey       string // 本场直播的标识，同一场直播相同
+	SubSessionKey string
+	LivePlatform  string // pc pc_link android ...
+	LiveModel     int
+	LiveTime      int64 // 开播时间，unix 秒，部分消息中没有
+}
+
+func (m *MsgLive) Parse() (*LiveStart, error) {
+	var r struct {
+		RoomID        json.RawMessage `json:"roomid"`
+		LiveKey       string          `json:"live_key"`
+		SubSessionKey string          `json:"sub_session_key"`
+		LivePlatform  string          `json:"live_platform"`
+		LiveModel     int             `json:"live_model"`
+		LiveTime      int64           `json:"live_time"`
+	}
+	if err := json.Unmarshal(m.raw, &r); err != nil {
+		return nil, err
+	}
+	id, err := parseFlexInt(r.RoomID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid roomid: %s", err)
+	}
+	return &LiveStart{
+		RoomID: id, LiveKey: r.LiveKey, SubSessionKey: r.SubSessionKey,
+		LivePlatform: r.LivePlatform, LiveModel: r.LiveModel, LiveTime: r.LiveTime,
+	}, nil
+}
+
 //
 
 // MsgRoomRank 排名改变
@@ -1214,6 +1546,7 @@ func (m *MsgBlock) Raw() []byte {
 
 //
 
+// MsgPkPre PK准备
 type MsgPkPre struct {
 	base
 }
@@ -1225,6 +1558,26 @@ func (m *MsgPkPre) Raw() []byte {
 	return m.raw
 }
 
+type PkPre struct {
+	PkHeader    `json:"-"`
+	InitID      int64  `json:"init_id"`  // 发起方直播间
+	MatchID     int64  `json:"match_id"` // 匹配方直播间
+	CountDown   int    `json:"count_down"`
+	PkTopic     string `json:"pk_topic"`
+	PkPreTime   int64  `json:"pk_pre_time"`
+	PkStartTime int64  `json:"pk_start_time"`
+	PkEndTime   int64  `json:"pk_end_time"`
+	EndTime     int64  `json:"end_time"` // 惩罚时间结束
+}
+
+func (m *MsgPkPre) Parse() (*PkPre, error) {
+	var r = &PkPre{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 // MsgPkEnd PK判断胜负
@@ -1239,8 +1592,24 @@ func (m *MsgPkEnd) Raw() []byte {
 	return m.raw
 }
 
+type PkEnd struct {
+	PkHeader    `json:"-"`
+	InitID      int64  `json:"init_id"`  // 发起方直播间
+	MatchID     int64  `json:"match_id"` // 匹配方直播间
+	PunishTopic string `json:"punish_topic"`
+}
+
+func (m *MsgPkEnd) Parse() (*PkEnd, error) {
+	var r = &PkEnd{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
+// MsgPkSettle PK结算
 type MsgPkSettle struct {
 	base
 }
@@ -1252,6 +1621,39 @@ func (m *MsgPkSettle) Raw() []byte {
 	return m.raw
 }
 
+// PkSettleRoom PK结算时一方的主播与票数
+type PkSettleRoom struct {
+	UID      int64  `json:"uid"`
+	InitID   int64  `json:"init_id"`  // 仅 init_info，直播间号
+	MatchID  int64  `json:"match_id"` // 仅 match_info，直播间号
+	Uname    string `json:"uname"`
+	Face     string `json:"face"`
+	Votes    int64  `json:"votes"`
+	IsWinner bool   `json:"is_winner"`
+}
+
+type PkSettle struct {
+	PkHeader    `json:"-"`
+	InitInfo    PkSettleRoom `json:"init_info"`  // 发起方
+	MatchInfo   PkSettleRoom `json:"match_info"` // 匹配方
+	PunishTopic string       `json:"punish_topic"`
+	BestUser    struct {
+		UID           int64  `json:"uid"`
+		Uname         string `json:"uname"`
+		Face          string `json:"face"`
+		VipType       int    `json:"vip_type"`
+		PrivilegeType int    `json:"privilege_type"` // 大航海等级
+	} `json:"best_user"` // 获胜方的最佳助攻
+}
+
+func (m *MsgPkSettle) Parse() (*PkSettle, error) {
+	var r = &PkSettle{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 type MsgSysGift struct {
@@ -1265,6 +1667,26 @@ func (m *MsgSysGift) Raw() []byte {
 	return m.raw
 }
 
+// SysGift 字段在顶层而不在 data 中
+type SysGift struct {
+	Msg        string `json:"msg"`
+	MsgText    string `json:"msg_text"`
+	RoomID     int64  `json:"roomid"`
+	RealRoomID int64  `json:"real_roomid"`
+	GiftID     int64  `json:"giftId"`
+	TvID       string `json:"tv_id"`
+	URL        string `json:"url"`
+	Rnd        string `json:"rnd"`
+}
+
+func (m *MsgSysGift) Parse() (*SysGift, error) {
+	var r = &SysGift{}
+	if err := json.Unmarshal(m.raw, &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 // MsgHotRank 热门榜xx榜topX
@@ -1279,6 +1701,26 @@ func (m *MsgHotRank) Raw() []byte {
 	return m.raw
 }
 
+type HotRank struct {
+	Rank      int    `json:"rank"`
+	Trend     int    `json:"trend"`
+	Countdown int    `json:"countdown"`
+	Timestamp int64  `json:"timestamp"`
+	AreaName  string `json:"area_name"`
+	RankType  string `json:"rank_type"`
+	RankDesc  string `json:"rank_desc"`
+	Icon      string `json:"icon"`
+	WebUrl    string `json:"web_url"`
+}
+
+func (m *MsgHotRank) Parse() (*HotRank, error) {
+	var r = &HotRank{}
+	if err := json.Unmarshal(getData(m.raw), &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 type MsgActivityRedPacket struct {
@@ -1294,6 +1736,7 @@ func (m *MsgActivityRedPacket) Raw() []byte {
 
 //
 
+// MsgPkMicEnd PK连麦结束
 type MsgPkMicEnd struct {
 	base
 }
@@ -1305,6 +1748,19 @@ func (m *MsgPkMicEnd) Raw() []byte {
 	return m.raw
 }
 
+type PkMicEnd struct {
+	PkHeader `json:"-"`
+	Type     int `json:"type"`
+}
+
+func (m *MsgPkMicEnd) Parse() (*PkMicEnd, error) {
+	var r = &PkMicEnd{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 type MsgPlayTag struct {
@@ -1332,6 +1788,39 @@ func (m *MsgGuardMsg) Raw() []byte {
 	return m.raw
 }
 
+// GuardMsg 字段在顶层而不在 data 中
+type GuardMsg struct {
+	Msg           string `json:"msg"` // :?一只鱼:? 在本房间开通了舰长
+	MsgNew        string `json:"msg_new"`
+	URL           string `json:"url"`
+	RoomID        int64  `json:"roomid"`
+	BuyType       int    `json:"buy_type"` // 同 guard_level
+	BroadcastType int    `json:"broadcast_type"`
+}
+
+// Uname 从 msg 中取出用户名，没有时返回空字符串
+func (g *GuardMsg) Uname() string {
+	const mark = ":?"
+	i := strings.Index(g.Msg, mark)
+	if i < 0 {
+		return ""
+	}
+	rest := g.Msg[i+len(mark):]
+	j := strings.Index(rest, mark)
+	if j < 0 {
+		return ""
+	}
+	return rest[:j]
+}
+
+func (m *MsgGuardMsg) Parse() (*GuardMsg, error) {
+	var r = &GuardMsg{}
+	if err := json.Unmarshal(m.raw, &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 type MsgPlayProgressBar struct {
@@ -1358,6 +1847,25 @@ func (m *MsgHotRoomNotify) Raw() []byte {
 	return m.raw
 }
 
+// HotRoomNotify 热门直播间的请求限流配置
+type HotRoomNotify struct {
+	Threshold        int `json:"threshold"`
+	TTL              int `json:"ttl"`
+	ExitNoRefresh    int `json:"exit_no_refresh"`
+	RandomDelayReqV2 []struct {
+		Path  string `json:"path"`
+		Delay int    `json:"delay"`
+	} `json:"random_delay_req_v2"`
+}
+
+func (m *MsgHotRoomNotify) Parse() (*HotRoomNotify, error) {
+	var r = &HotRoomNotify{}
+	if err := json.Unmarshal(getData(m.raw), &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 type MsgRefresh struct {
@@ -1411,6 +1919,51 @@ func (m *MsgEntryEffect) Raw() []byte {
 	return m.raw
 }
 
+type EntryEffect struct {
+	ID               int    `json:"id"`
+	UID              int64  `json:"uid"`
+	TargetID         int64  `json:"target_id"` // 主播UID
+	MockEffect       int    `json:"mock_effect"`
+	Face             string `json:"face"`
+	PrivilegeType    int    `json:"privilege_type"` // 大航海等级，0 为非舰队成员
+	CopyWriting      string `json:"copy_writing"`   // 欢迎舰长 <%一只鱼%> 进入直播间
+	CopyWritingV2    string `json:"copy_writing_v2"`
+	CopyColor        string `json:"copy_color"`
+	HighlightColor   string `json:"highlight_color"`
+	Priority         int    `json:"priority"`
+	BasemapURL       string `json:"basemap_url"`
+	ShowAvatar       int    `json:"show_avatar"`
+	EffectiveTime    int    `json:"effective_time"`
+	WebBasemapURL    string `json:"web_basemap_url"`
+	WebEffectiveTime int    `json:"web_effective_time"`
+	Business         int    `json:"business"`
+	MaxDelayTime     int    `json:"max_delay_time"`
+	TriggerTime      int64  `json:"trigger_time"` // 纳秒
+	Identities       int    `json:"identities"`
+}
+
+// Uname 从 copy_writing 中取出用户名，没有时返回空字符串
+func (e *EntryEffect) Uname() string {
+	i := strings.Index(e.CopyWriting, "<%")
+	if i < 0 {
+		return ""
+	}
+	rest := e.CopyWriting[i+2:]
+	j := strings.Index(rest, "%>")
+	if j < 0 {
+		return ""
+	}
+	return rest[:j]
+}
+
+func (m *MsgEntryEffect) Parse() (*EntryEffect, error) {
+	var r = &EntryEffect{}
+	if err := json.Unmarshal(getData(m.raw), &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 // MsgWelcome 欢迎进入房间(似乎已废弃)
@@ -1466,6 +2019,27 @@ func (m *MsgCutOff) Raw() []byte {
 	return m.raw
 }
 
+// CutOff 字段在顶层
+type CutOff struct {
+	Msg    string // 切断原因
+	RoomID int64
+}
+
+func (m *MsgCutOff) Parse() (*CutOff, error) {
+	var r struct {
+		Msg    string          `json:"msg"`
+		RoomID json.RawMessage `json:"roomid"`
+	}
+	if err := json.Unmarshal(m.raw, &r); err != nil {
+		return nil, err
+	}
+	id, err := parseFlexInt(r.RoomID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid roomid: %s", err)
+	}
+	return &CutOff{Msg: r.Msg, RoomID: id}, nil
+}
+
 //
 
 // MsgSpecialGift 节奏风暴
@@ -1480,6 +2054,38 @@ func (m *MsgSpecialGift) Raw() []byte {
 	return m.raw
 }
 
+// SpecialGift data 以礼物ID为键，节奏风暴为 39
+type SpecialGift struct {
+	GiftID   int64  `json:"-"`
+	ID       string `json:"id"`
+	Time     int    `json:"time"`
+	HadJoin  int    `json:"hadJoin"`
+	Num      int    `json:"num"`
+	Content  string `json:"content"` // 节奏风暴的口令
+	Action   string `json:"action"`  // start end
+	StormGif string `json:"storm_gif"`
+}
+
+func (m *MsgSpecialGift) Parse() (*SpecialGift, error) {
+	var d map[string]json.RawMessage
+	if err := json.Unmarshal(getData(m.raw), &d); err != nil {
+		return nil, err
+	}
+	for k, v := range d {
+		var r = &SpecialGift{}
+		if err := json.Unmarshal(v, &r); err != nil {
+			return nil, err
+		}
+		id, err := parseFlexInt(json.RawMessage(k))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gift id %q: %s", k, err)
+		}
+		r.GiftID = id
+		return r, nil
+	}
+	return nil, fmt.Errorf("empty SPECIAL_GIFT data")
+}
+
 //
 
 // MsgNewGuardCount 船员数量改变事件
@@ -1494,6 +2100,26 @@ func (m *MsgNewGuardCount) Raw() []byte {
 	return m.raw
 }
 
+type NewGuardCount struct {
+	Count     int   `json:"count"` // 当前船员数量
+	RoomID    int64 `json:"roomid"`
+	AnchorUID int64 `json:"anchor_uid"`
+}
+
+// Parse 字段可能在顶层或 data 中，data 优先
+func (m *MsgNewGuardCount) Parse() (*NewGuardCount, error) {
+	var r = &NewGuardCount{}
+	if err := json.Unmarshal(m.raw, &r); err != nil {
+		return nil, err
+	}
+	if d := getData(m.raw); len(d) > 0 && string(d) != "null" {
+		if err := json.Unmarshal(d, &r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
 //
 
 // MsgRoomAdmins 房管数量改变
@@ -1566,10 +2192,19 @@ type InteractWord struct {
 		MedalColorBorder int64  `json:"medal_color_border"`
 		MedalColorEnd    int64  `json:"medal_color_end"`
 	} `json:"fans_medal"`
-	MsgType    int    `json:"msg_type"`
+	MsgType    int    `json:"msg_type"` // 参见 InteractEnter
 	SpreadInfo string `json:"spread_info"`
 }
 
+// InteractWord 的 msg_type
+const (
+	InteractEnter         = 1 // 进入直播间
+	InteractFollow        = 2 // 关注
+	InteractShare         = 3 // 分享
+	InteractSpecialFollow = 4 // 特别关注
+	InteractMutualFollow  = 5 // 互相关注
+)
+
 func (m *MsgInteractWord) Parse() (*InteractWord, error) {
 	var r = &InteractWord{}
 	if err := json.Unmarshal(getData(m.raw), &r); err != nil {
@@ -1580,6 +2215,100 @@ func (m *MsgInteractWord) Parse() (*InteractWord, error) {
 
 //
 
+// PkHeader PK 消息顶层的公共字段
+type PkHeader struct {
+	PkID      int64 // 本场PK的ID，用于关联同一场PK的消息
+	PkStatus  int   // 101:准备 201:进行中 301:惩罚时间 401:结束
+	Timestamp int64
+}
+
+// parsePk 解析顶层的 PkHeader 与 data。部分消息的 pk_id 为字符串
+func parsePk(raw []byte, h *PkHeader, data interface{}) error {
+	var t struct {
+		PkID      json.RawMessage `json:"pk_id"`
+		PkStatus  int             `json:"pk_status"`
+		Timestamp int64           `json:"timestamp"`
+	}
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return err
+	}
+	id, err := parseFlexInt(t.PkID)
+	if err != nil {
+		return fmt.Errorf("invalid pk_id: %s", err)
+	}
+	h.PkID, h.PkStatus, h.Timestamp = id, t.PkStatus, t.Timestamp
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(getData(raw), data)
+}
+
+// parseFlexInt 解析数字或数字字符串，空值为 0
+func parseFlexInt(b json.RawMessage) (int64, error) {
+	if len(b) == 0 || string(b) == "null" || string(b) == `""` {
+		return 0, nil
+	}
+	if b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return 0, err
+		}
+		b = json.RawMessage(s)
+	}
+	var n int64
+	err := json.Unmarshal(b, &n)
+	return n, err
+}
+
+// PkResult PK结果，对应 winner_type、result_type
+const (
+	PkResultLose = -1 // 负
+	PkResultDraw = 1  // 平
+	PkResultWin  = 2  // 胜
+)
+
+// PkRoom PK中一方的实时数据。init_info 为发起方，match_info 为匹配方，本直播间可能是其中任意一方
+type PkRoom struct {
+	RoomID     int64  `json:"room_id"`
+	Votes      int64  `json:"votes"`       // 乱斗值
+	BestUname  string `json:"best_uname"`  // 最佳助攻
+	WinnerType int    `json:"winner_type"` // 仅 PK_BATTLE_END，参见 PkResultWin
+	VisionDesc int    `json:"vision_desc"` //
+}
+
+// PkUser PK结算时的用户信息
+type PkUser struct {
+	RoomID    int64  `json:"room_id"`
+	UID       int64  `json:"uid"`
+	Uname     string `json:"uname"`
+	Face      string `json:"face"`
+	FaceFrame string `json:"face_frame"`
+	Exp       struct {
+		Color       int64 `json:"color"`
+		UserLevel   int   `json:"user_level"`
+		MasterLevel struct {
+			Color int64 `json:"color"`
+			Level int   `json:"level"`
+		} `json:"master_level"`
+	} `json:"exp"`
+}
+
+// PkResultInfo PK结算的得分详情
+type PkResultInfo struct {
+	TotalScore        int    `json:"total_score"`
+	ResultTypeScore   int    `json:"result_type_score"`
+	PkVotes           int64  `json:"pk_votes"`
+	PkVotesName       string `json:"pk_votes_name"`
+	PkCritScore       int    `json:"pk_crit_score"`
+	PkResistCritScore int    `json:"pk_resist_crit_score"`
+	PkExtraScore      int    `json:"pk_extra_score"`
+	PkTaskScore       int    `json:"pk_task_score"`
+	PkTimesScore      int    `json:"pk_times_score"`
+	WinCount          int    `json:"win_count"`
+	WinFinalHit       int    `json:"win_final_hit"`
+	WinnerCountScore  int    `json:"winner_count_score"`
+}
+
 // MsgPkBattlePre 大乱斗准备，10秒后开始
 type MsgPkBattlePre struct {
 	base
@@ -1592,6 +2321,28 @@ func (m *MsgPkBattlePre) Raw() []byte {
 	return m.raw
 }
 
+// PkBattlePre data 中为对面主播的信息
+type PkBattlePre struct {
+	PkHeader    `json:"-"`
+	BattleType  int    `json:"battle_type"`
+	MatchType   int    `json:"match_type"`
+	Uname       string `json:"uname"`   // 对面主播
+	Face        string `json:"face"`    //
+	UID         int64  `json:"uid"`     //
+	RoomID      int64  `json:"room_id"` // 对面直播间
+	SeasonID    int    `json:"season_id"`
+	PreTimer    int    `json:"pre_timer"` // 距离开始的秒数
+	PkVotesName string `json:"pk_votes_name"`
+}
+
+func (m *MsgPkBattlePre) Parse() (*PkBattlePre, error) {
+	var r = &PkBattlePre{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 type MsgPkBattleSettle struct {
@@ -1605,6 +2356,21 @@ func (m *MsgPkBattleSettle) Raw() []byte {
 	return m.raw
 }
 
+type PkBattleSettle struct {
+	PkHeader     `json:"-"`
+	BattleType   int    `json:"battle_type"`
+	ResultType   int    `json:"result_type"` // 参见 PkResultWin
+	StarLightMsg string `json:"star_light_msg"`
+}
+
+func (m *MsgPkBattleSettle) Parse() (*PkBattleSettle, error) {
+	var r = &PkBattleSettle{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 // MsgPkBattleStart 大乱斗开始
@@ -1619,6 +2385,35 @@ func (m *MsgPkBattleStart) Raw() []byte {
 	return m.raw
 }
 
+type PkBattleStart struct {
+	PkHeader      `json:"-"`
+	BattleType    int    `json:"battle_type"`
+	FinalHitVotes int64  `json:"final_hit_votes"`
+	StartTime     int64  `json:"pk_start_time"`
+	FrozenTime    int64  `json:"pk_frozen_time"` // 停止计票的时间
+	EndTime       int64  `json:"pk_end_time"`
+	PkVotesType   int    `json:"pk_votes_type"`
+	PkVotesAdd    int    `json:"pk_votes_add"`
+	PkVotesName   string `json:"pk_votes_name"`
+	StarLightMsg  string `json:"star_light_msg"`
+	InitInfo      struct {
+		RoomID     int64 `json:"room_id"`
+		DateStreak int   `json:"date_streak"`
+	} `json:"init_info"` // 发起方直播间
+	MatchInfo struct {
+		RoomID     int64 `json:"room_id"`
+		DateStreak int   `json:"date_streak"`
+	} `json:"match_info"` // 匹配方直播间
+}
+
+func (m *MsgPkBattleStart) Parse() (*PkBattleStart, error) {
+	var r = &PkBattleStart{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 // MsgPkBattleProcess 大乱斗双方送礼
@@ -1633,6 +2428,21 @@ func (m *MsgPkBattleProcess) Raw() []byte {
 	return m.raw
 }
 
+type PkBattleProcess struct {
+	PkHeader   `json:"-"`
+	BattleType int    `json:"battle_type"`
+	InitInfo   PkRoom `json:"init_info"`  // 发起方直播间
+	MatchInfo  PkRoom `json:"match_info"` // 匹配方直播间
+}
+
+func (m *MsgPkBattleProcess) Parse() (*PkBattleProcess, error) {
+	var r = &PkBattleProcess{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 // MsgPkEnding 大乱斗尾声，最后几秒
@@ -1647,6 +2457,15 @@ func (m *MsgPkEnding) Raw() []byte {
 	return m.raw
 }
 
+// GetHeader PK_ENDING 只关心是哪一场PK
+func (m *MsgPkEnding) GetHeader() (*PkHeader, error) {
+	var r = &PkHeader{}
+	if err := parsePk(m.raw, r, nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 // MsgPkBattleEnd 大乱斗结束
@@ -1661,6 +2480,33 @@ func (m *MsgPkBattleEnd) Raw() []byte {
 	return m.raw
 }
 
+type PkBattleEnd struct {
+	PkHeader   `json:"-"`
+	BattleType int    `json:"battle_type"`
+	Timer      int    `json:"timer"`      // 惩罚时间
+	InitInfo   PkRoom `json:"init_info"`  // 发起方直播间
+	MatchInfo  PkRoom `json:"match_info"` // 匹配方直播间
+}
+
+// Winner 获胜方的直播间号，平局返回 0
+func (p *PkBattleEnd) Winner() int64 {
+	switch {
+	case p.InitInfo.WinnerType == PkResultWin:
+		return p.InitInfo.RoomID
+	case p.MatchInfo.WinnerType == PkResultWin:
+		return p.MatchInfo.RoomID
+	}
+	return 0
+}
+
+func (m *MsgPkBattleEnd) Parse() (*PkBattleEnd, error) {
+	var r = &PkBattleEnd{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 type MsgPkBattleSettleUser struct {
@@ -1674,6 +2520,24 @@ func (m *MsgPkBattleSettleUser) Raw() []byte {
 	return m.raw
 }
 
+type PkBattleSettleUser struct {
+	PkHeader     `json:"-"`
+	SettleStatus int          `json:"settle_status"`
+	ResultType   int          `json:"result_type"` // 参见 PkResultWin
+	BattleType   int          `json:"battle_type"`
+	ResultInfo   PkResultInfo `json:"result_info"`
+	Winner       PkUser       `json:"winner"`  // 获胜方主播
+	MyInfo       PkUser       `json:"my_info"` // 本直播间主播
+}
+
+func (m *MsgPkBattleSettleUser) Parse() (*PkBattleSettleUser, error) {
+	var r = &PkBattleSettleUser{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 type MsgPkBattleSettleV2 struct {
@@ -1687,6 +2551,35 @@ func (m *MsgPkBattleSettleV2) Raw() []byte {
 	return m.raw
 }
 
+type PkBattleSettleV2 struct {
+	PkHeader      `json:"-"`
+	SettleStatus  int          `json:"settle_status"`
+	PunishEndTime int64        `json:"punish_end_time"`
+	ResultType    int          `json:"result_type"` // 参见 PkResultWin
+	StarLightMsg  string       `json:"star_light_msg"`
+	ResultInfo    PkResultInfo `json:"result_info"`
+	AssistList    []struct {
+		ID    int64  `json:"id"`
+		Uname string `json:"uname"`
+		Face  string `json:"face"`
+		Score int64  `json:"score"`
+	} `json:"assist_list"` // 本直播间助攻榜
+	LevelInfo struct {
+		FirstRankName  string `json:"first_rank_name"`
+		SecondRankNum  int    `json:"second_rank_num"`
+		FirstRankImg   string `json:"first_rank_img"`
+		SecondRankIcon string `json:"second_rank_icon"`
+	} `json:"level_info"`
+}
+
+func (m *MsgPkBattleSettleV2) Parse() (*PkBattleSettleV2, error) {
+	var r = &PkBattleSettleV2{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 // MsgPkLotteryStart 大乱斗胜利后的抽奖
@@ -1701,9 +2594,49 @@ func (m *MsgPkLotteryStart) Raw() []byte {
 	return m.raw
 }
 
+type PkLotteryStart struct {
+	AssetAnimationPic string `json:"asset_animation_pic"`
+	AssetIcon         string `json:"asset_icon"`
+	FromUser          struct {
+		UID   int64  `json:"uid"`
+		Uname string `json:"uname"`
+		Face  string `json:"face"`
+	} `json:"from_user"`
+	ID      int64  `json:"id"`
+	MaxTime int    `json:"max_time"`
+	PkID    int64  `json:"-"` // pk_id 可能为字符串
+	RoomID  int64  `json:"room_id"`
+	Time    int    `json:"time"`
+	Title   string `json:"title"`
+	Weight  int    `json:"weight"`
+}
+
+func (m *MsgPkLotteryStart) Parse() (*PkLotteryStart, error) {
+	var (
+		r    = &PkLotteryStart{}
+		data = getData(m.raw)
+		t    struct {
+			PkID json.RawMessage `json:"pk_id"`
+		}
+	)
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	id, err := parseFlexInt(t.PkID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pk_id: %s", err)
+	}
+	r.PkID = id
+	return r, nil
+}
+
 //
 
-// MsgPkBestUname PK最佳助攻
+// MsgPkBestUname PK最佳助攻。没有可靠的样本，暂不提供 Parse，
+// 最佳助攻可以从 PkRoom.BestUname、PkSettle.BestUser 与 PkBattleSettleV2.AssistList 得到
 type MsgPkBestUname struct {
 	base
 }
@@ -1785,9 +2718,25 @@ func (m *MsgPkMatchInfo) Raw() []byte {
 	return m.raw
 }
 
+type PkMatchInfo struct {
+	PkHeader `json:"-"`
+	RoomID   int64  `json:"room_id"` // 对面直播间
+	UID      int64  `json:"uid"`
+	Uname    string `json:"uname"`
+	Face     string `json:"face"`
+}
+
+func (m *MsgPkMatchInfo) Parse() (*PkMatchInfo, error) {
+	var r = &PkMatchInfo{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
-// MsgPkMatchOnlineGuard 获取对面直播间舰长在线人数
+// MsgPkMatchOnlineGuard 获取对面直播间舰长在线人数。没有可靠的样本，暂不提供 Parse
 type MsgPkMatchOnlineGuard struct {
 	base
 }
@@ -1813,6 +2762,20 @@ func (m *MsgPkWinningStreak) Raw() []byte {
 	return m.raw
 }
 
+type PkWinningStreak struct {
+	PkHeader `json:"-"`
+	RoomID   int64 `json:"room_id"`
+	Streak   int   `json:"streak"` // 连胜场数
+}
+
+func (m *MsgPkWinningStreak) Parse() (*PkWinningStreak, error) {
+	var r = &PkWinningStreak{}
+	if err := parsePk(m.raw, &r.PkHeader, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 //
 
 // MsgPkDanmuMsg 对面的弹幕消息
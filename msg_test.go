@@ -0,0 +1,290 @@
+package live
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 按直播间实际下发的格式整理的 DANMU_MSG
+const danmakuFull = `{"cmd":"DANMU_MSG","info":[[0,1,25,16777215,1651398745432,1651398686,0,"f1a0c0b4",0,0,0,"",0,"{}","{}",{"mode":0,"show_player_type":0,"extra":"{\"send_from_me\":false,\"mode\":0,\"color\":16777215,\"dm_type\":0,\"font_size\":25,\"player_mode\":1,\"show_player_type\":0,\"content\":\"主播好\",\"user_hash\":\"4053843124\",\"emoticon_unique\":\"\",\"bulge_display\":0,\"recommend_score\":0,\"main_state_dm_color\":\"\",\"objective_state_dm_color\":\"\",\"direction\":0,\"pk_direction\":0,\"quartet_direction\":0,\"anniversary_crowd\":0,\"yeah_space_type\":\"\",\"yeah_space_url\":\"\",\"jump_to_url\":\"\",\"space_type\":\"\",\"space_url\":\"\",\"animation\":{},\"emots\":null}"},{"activity_identity":"","activity_source":0,"not_show":0}],"主播好",[2920960,"一只鱼",0,0,0,10000,1,""],[21,"鱼粉","老番茄",21852,1725515,"",0,1725515,1725515,1725515,0,1,546195],[25,0,5805790,">50000",0],["",""],0,0,null,{"ts":1651398745,"ct":"B2C3D4E5"},0,0,null,null,0,210]}`
+
+// 回复他人并带有内联表情的舰长弹幕
+const danmakuReply = `{"cmd":"DANMU_MSG","info":[[0,1,25,16777215,1651398800123,1651398790,0,"a1b2c3d4",0,0,0,"",0,"{}","{}",{"mode":0,"show_player_type":0,"extra":"{\"content\":\"@一只鱼 [dog]哈哈\",\"emots\":{\"[dog]\":{\"count\":1,\"descript\":\"[dog]\",\"emoji\":\"[dog]\",\"emoticon_id\":208,\"emoticon_unique\":\"emoji_208\",\"height\":20,\"url\":\"http://i0.hdslb.com/bfs/live/4428c84e694fbf4e0ef6c06e958d9352c3582740.png\",\"width\":20}},\"reply_mid\":2920960,\"reply_uname\":\"一只鱼\",\"reply_uname_color\":\"\",\"reply_is_mystery\":false}"}],"@一只鱼 [dog]哈哈",[3003,"舰长大人",0,0,0,10000,1,"#E17AFF"],[24,"鱼粉","老番茄",21852,1725515,"",0,6809855,1725515,5414290,3,1,546195],[31,0,9868950,">50000",0],["title-111-1","title-111-1"],0,3,null,{"ts":1651398800,"ct":"AB12CD34"},0,0,null,null,0,210]}`
+
+// 表情包弹幕
+const danmakuEmoticon = `{"cmd":"DANMU_MSG","info":[[0,1,25,16777215,1651398900000,1651398890,0,"b2c3d4e5",0,0,0,"",1,{"bulge_display":1,"emoticon_unique":"official_147","height":60,"in_player_area":1,"is_dynamic":0,"url":"http://i0.hdslb.com/bfs/live/a98e35996545509188fe4d24bd1a56518ea5af48.png","width":183},"{}",{"extra":"{\"emots\":null}"}],"赞",[1002,"路人乙",0,0,0,10000,1,""],[],[3,0,9868950,">50000",0],["",""],0,0]}`
+
+// 未佩戴勋章、等级信息缺失的弹幕
+const danmakuNoMedal = `{"cmd":"DANMU_MSG","info":[[0,1,25,14893055,1651398750001,1651398690,0,"7c2a1b3d",0,0,0,"",0,"{}","{}",{}],"awsl",[1001,"路人",1,0,0,10000,1,"#00D1F1"],[]]}`
+
+func TestMsgDanmakuParse(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want Danmaku
+		err  string
+	}{
+		{
+			name: "full",
+			raw:  danmakuFull,
+			want: Danmaku{SendMode: 1, SendFontSize: 25, DanmakuColor: 16777215, Time: 1651398745432, DMID: 1651398686,
+				Content: "主播好", MID: 2920960, Uname: "一只鱼", Rank: 10000, MobileVerify: 1,
+				MedalLevel: 21, MedalName: "鱼粉", UpName: "老番茄", UserLevel: 25,
+				Medal: &DanmakuMedal{Level: 21, Name: "鱼粉", UpName: "老番茄", RoomID: 21852, Color: 1725515,
+					ColorBorder: 1725515, ColorStart: 1725515, ColorEnd: 1725515, IsLighted: 1, UpUID: 546195}},
+		},
+		{
+			name: "reply with emots",
+			raw:  danmakuReply,
+			want: Danmaku{SendMode: 1, SendFontSize: 25, DanmakuColor: 16777215, Time: 1651398800123, DMID: 1651398790,
+				Content: "@一只鱼 [dog]哈哈", MID: 3003, Uname: "舰长大人", Rank: 10000, MobileVerify: 1, UnameColor: "#E17AFF",
+				MedalLevel: 24, MedalName: "鱼粉", UpName: "老番茄", UserLevel: 31,
+				Emots: map[string]*DanmakuEmot{"[dog]": {EmoticonID: 208, EmoticonUnique: "emoji_208", Emoji: "[dog]", Descript: "[dog]",
+					URL: "http://i0.hdslb.com/bfs/live/4428c84e694fbf4e0ef6c06e958d9352c3582740.png", Width: 20, Height: 20, Count: 1}},
+				Reply: &DanmakuReply{MID: 2920960, Uname: "一只鱼"},
+				Medal: &DanmakuMedal{Level: 24, Name: "鱼粉", UpName: "老番茄", RoomID: 21852, Color: 1725515,
+					ColorBorder: 6809855, ColorStart: 1725515, ColorEnd: 5414290, GuardLevel: 3, IsLighted: 1, UpUID: 546195},
+				Title: "title-111-1", GuardLevel: 3},
+		},
+		{
+			name: "emoticon",
+			raw:  danmakuEmoticon,
+			want: Danmaku{SendMode: 1, SendFontSize: 25, DanmakuColor: 16777215, Time: 1651398900000, DMID: 1651398890,
+				Content: "赞", MID: 1002, Uname: "路人乙", Rank: 10000, MobileVerify: 1, UserLevel: 3,
+				Emoticon: &DanmakuEmoticon{EmoticonUnique: "official_147", URL: "http://i0.hdslb.com/bfs/live/a98e35996545509188fe4d24bd1a56518ea5af48.png",
+					Width: 183, Height: 60, InPlayerArea: 1, BulgeDisplay: 1}},
+		},
+		{
+			name: "no medal",
+			raw:  danmakuNoMedal,
+			want: Danmaku{SendMode: 1, SendFontSize: 25, DanmakuColor: 14893055, Time: 1651398750001, DMID: 1651398690,
+				Content: "awsl", MID: 1001, Uname: "路人", RoomAdmin: 1, Rank: 10000, MobileVerify: 1, UnameColor: "#00D1F1"},
+		},
+		{
+			name: "short info",
+			raw:  `{"cmd":"DANMU_MSG","info":[[0,1,25,16777215,1651398745432,1651398686,0,"f1a0c0b4",0,0,0,""],"hi"]}`,
+			err:  "missing info[2] (len 2)",
+		},
+		{
+			name: "short info[0]",
+			raw:  `{"cmd":"DANMU_MSG","info":[[0,1,25,16777215],"hi",[1,"a",0,0,0,0,0,""]]}`,
+			err:  "missing info[0][4] (len 4)",
+		},
+		{
+			name: "short info[2]",
+			raw:  `{"cmd":"DANMU_MSG","info":[[0,1,25,16777215,1651398745432,1651398686,0,"f1a0c0b4",0,0,0,""],"hi",[1,"a"]]}`,
+			err:  "missing info[2][2] (len 2)",
+		},
+		{
+			name: "wrong type",
+			raw:  `{"cmd":"DANMU_MSG","info":[[0,1,25,16777215,1651398745432,1651398686,0,"f1a0c0b4",0,0,0,""],123,[1,"a",0,0,0,0,0,""]]}`,
+			err:  "invalid info[1]",
+		},
+		{
+			name: "no info",
+			raw:  `{"cmd":"DANMU_MSG"}`,
+			err:  "missing info[0] (len 0)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dm, err := (&MsgDanmaku{base: base{raw: []byte(tt.raw)}}).Parse()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*dm, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", *dm, tt.want)
+			}
+		})
+	}
+}
+
+// 按直播间实际下发的格式整理的一场大乱斗，pk_id 在部分消息中为字符串
+const (
+	pkBattlePre        = `{"cmd":"PK_BATTLE_PRE","pk_id":300458312,"pk_status":101,"timestamp":1651400000,"data":{"battle_type":1,"match_type":1,"uname":"对面主播","face":"http://i0.hdslb.com/face.jpg","uid":1000,"room_id":22000,"season_id":40,"pre_timer":10,"pk_votes_name":"乱斗值","end_win_task":null},"roomid":21852}`
+	pkBattleProcess    = `{"cmd":"PK_BATTLE_PROCESS","pk_id":300458312,"pk_status":201,"timestamp":1651400060,"data":{"battle_type":1,"init_info":{"room_id":21852,"votes":520,"best_uname":"一只鱼","vision_desc":0},"match_info":{"room_id":22000,"votes":100,"best_uname":"路人","vision_desc":0}}}`
+	pkBattleEnd        = `{"cmd":"PK_BATTLE_END","pk_id":"300458312","pk_status":401,"timestamp":1651400310,"data":{"battle_type":1,"timer":10,"init_info":{"room_id":21852,"votes":1314,"winner_type":2,"best_uname":"一只鱼"},"match_info":{"room_id":22000,"votes":100,"winner_type":-1,"best_uname":"路人"}}}`
+	pkBattleSettleV2   = `{"cmd":"PK_BATTLE_SETTLE_V2","pk_id":300458312,"pk_status":401,"settle_status":1,"timestamp":1651400310,"data":{"pk_id":"300458312","pk_status":401,"settle_status":1,"punish_end_time":1651400490,"timestamp":1651400310,"battle_type":1,"result_type":2,"star_light_msg":"","result_info":{"total_score":12,"result_type_score":12,"pk_votes":1314,"pk_votes_name":"乱斗值","pk_crit_score":-1,"pk_resist_crit_score":-1,"pk_extra_score_slot":"","pk_extra_value":0,"pk_extra_score":0,"pk_task_score":0,"pk_times_score":0,"pk_done_times":0,"pk_total_times":0,"win_count":2,"win_final_hit":-1,"winner_count_score":0,"task_score_list":[]},"level_info":{"first_rank_name":"白银斗士","second_rank_num":3,"first_rank_img":"","second_rank_icon":""},"assist_list":[{"id":2920960,"uname":"一只鱼","face":"http://i0.hdslb.com/a.jpg","score":1000},{"id":1001,"uname":"路人","face":"http://i0.hdslb.com/b.jpg","score":314}]}}`
+	pkBattleStart      = `{"cmd":"PK_BATTLE_START","pk_id":300458312,"pk_status":201,"timestamp":1651400010,"data":{"battle_type":1,"final_hit_votes":0,"pk_start_time":1651400010,"pk_frozen_time":1651400310,"pk_end_time":1651400320,"pk_votes_type":0,"pk_votes_add":0,"pk_votes_name":"乱斗值","star_light_msg":"","init_info":{"room_id":22000,"date_streak":0},"match_info":{"room_id":21852,"date_streak":2}}}`
+	pkBattleSettle     = `{"cmd":"PK_BATTLE_SETTLE","pk_id":300458312,"pk_status":401,"settle_status":1,"timestamp":1651400310,"data":{"battle_type":1,"result_type":2,"star_light_msg":""},"roomid":21852}`
+	pkBattleSettleUser = `{"cmd":"PK_BATTLE_SETTLE_USER","pk_id":"300458312","pk_status":401,"settle_status":1,"timestamp":1651400310,"data":{"pk_id":"300458312","settle_status":1,"result_type":2,"battle_type":1,"result_info":{"total_score":12,"pk_votes":1314,"pk_votes_name":"乱斗值","win_count":2},"winner":{"room_id":21852,"uid":546195,"uname":"老番茄","face":"http://i0.hdslb.com/c.jpg","face_frame":"","exp":{"color":5805790,"user_level":39,"master_level":{"color":10512625,"level":40}}},"my_info":{"room_id":21852,"uid":546195,"uname":"老番茄","face":"http://i0.hdslb.com/c.jpg","face_frame":"","exp":{"color":5805790,"user_level":39,"master_level":{"color":10512625,"level":40}}}}}`
+	pkMatchInfo        = `{"cmd":"PK_MATCH_INFO","pk_id":"300458312","pk_status":101,"timestamp":1651400000,"data":{"room_id":22000,"uid":1000,"uname":"对面主播","face":"http://i0.hdslb.com/face.jpg"}}`
+	pkWinningStreak    = `{"cmd":"PK_WINNING_STREAK","pk_id":300458312,"pk_status":401,"timestamp":1651400310,"data":{"room_id":21852,"streak":3}}`
+	pkLotteryStart     = `{"cmd":"PK_LOTTERY_START","data":{"asset_animation_pic":"","asset_icon":"","from_user":{"uid":546195,"uname":"老番茄","face":""},"id":1234567,"max_time":120,"pk_id":"300458312","room_id":21852,"time":120,"title":"恭喜主播大乱斗胜利","weight":0}}`
+	pkPre              = `{"cmd":"PK_PRE","pk_id":1020304,"pk_status":101,"data":{"init_id":21852,"match_id":22000,"count_down":5,"pk_topic":"唱歌","pk_pre_time":1651400000,"pk_start_time":1651400005,"pk_end_time":1651400305,"end_time":1651400365}}`
+	pkEnd              = `{"cmd":"PK_END","pk_id":"1020304","pk_status":401,"data":{"init_id":21852,"match_id":22000,"punish_topic":"惩罚：学猫叫"}}`
+	pkSettle           = `{"cmd":"PK_SETTLE","pk_id":1020304,"pk_status":501,"data":{"pk_id":1020304,"init_info":{"uid":546195,"init_id":21852,"uname":"老番茄","face":"","votes":520,"is_winner":true},"match_info":{"uid":1000,"match_id":22000,"uname":"对面主播","face":"","votes":100,"is_winner":false},"best_user":{"uid":2920960,"uname":"一只鱼","face":"","vip_type":2,"privilege_type":3},"punish_topic":"惩罚：学猫叫"}}`
+	pkMicEnd           = `{"cmd":"PK_MIC_END","pk_id":1020304,"pk_status":1000,"data":{"type":0}}`
+)
+
+func TestMsgPkParse(t *testing.T) {
+	pre, err := (&MsgPkBattlePre{base{raw: []byte(pkBattlePre)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pre.PkID != 300458312 || pre.PkStatus != 101 || pre.RoomID != 22000 || pre.Uname != "对面主播" || pre.PreTimer != 10 {
+		t.Errorf("PK_BATTLE_PRE = %+v", pre)
+	}
+
+	p, err := (&MsgPkBattleProcess{base{raw: []byte(pkBattleProcess)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.InitInfo.Votes != 520 || p.MatchInfo.Votes != 100 || p.InitInfo.BestUname != "一只鱼" {
+		t.Errorf("PK_BATTLE_PROCESS = %+v", p)
+	}
+
+	end, err := (&MsgPkBattleEnd{base{raw: []byte(pkBattleEnd)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end.PkID != 300458312 || end.Winner() != 21852 || end.Timer != 10 {
+		t.Errorf("PK_BATTLE_END = %+v, winner %d", end, end.Winner())
+	}
+
+	s, err := (&MsgPkBattleSettleV2{base{raw: []byte(pkBattleSettleV2)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.PkID != 300458312 || s.ResultType != PkResultWin || s.ResultInfo.PkVotes != 1314 || s.ResultInfo.WinCount != 2 ||
+		len(s.AssistList) != 2 || s.AssistList[0].Uname != "一只鱼" || s.AssistList[0].Score != 1000 {
+		t.Errorf("PK_BATTLE_SETTLE_V2 = %+v", s)
+	}
+
+	start, err := (&MsgPkBattleStart{base{raw: []byte(pkBattleStart)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start.PkID != 300458312 || start.EndTime != 1651400320 || start.FrozenTime != 1651400310 || start.MatchInfo.RoomID != 21852 || start.MatchInfo.DateStreak != 2 {
+		t.Errorf("PK_BATTLE_START = %+v", start)
+	}
+
+	settle, err := (&MsgPkBattleSettle{base{raw: []byte(pkBattleSettle)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settle.PkID != 300458312 || settle.ResultType != PkResultWin {
+		t.Errorf("PK_BATTLE_SETTLE = %+v", settle)
+	}
+
+	su, err := (&MsgPkBattleSettleUser{base{raw: []byte(pkBattleSettleUser)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if su.PkID != 300458312 || su.ResultInfo.PkVotes != 1314 || su.Winner.UID != 546195 || su.MyInfo.Exp.MasterLevel.Level != 40 {
+		t.Errorf("PK_BATTLE_SETTLE_USER = %+v", su)
+	}
+
+	mi, err := (&MsgPkMatchInfo{base{raw: []byte(pkMatchInfo)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mi.PkID != 300458312 || mi.RoomID != 22000 || mi.Uname != "对面主播" {
+		t.Errorf("PK_MATCH_INFO = %+v", mi)
+	}
+
+	ws, err := (&MsgPkWinningStreak{base{raw: []byte(pkWinningStreak)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws.PkID != 300458312 || ws.RoomID != 21852 || ws.Streak != 3 {
+		t.Errorf("PK_WINNING_STREAK = %+v", ws)
+	}
+
+	lot, err := (&MsgPkLotteryStart{base{raw: []byte(pkLotteryStart)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lot.PkID != 300458312 || lot.ID != 1234567 || lot.FromUser.UID != 546195 || lot.Time != 120 {
+		t.Errorf("PK_LOTTERY_START = %+v", lot)
+	}
+
+	pp, err := (&MsgPkPre{base{raw: []byte(pkPre)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pp.PkID != 1020304 || pp.InitID != 21852 || pp.MatchID != 22000 || pp.CountDown != 5 || pp.PkEndTime != 1651400305 {
+		t.Errorf("PK_PRE = %+v", pp)
+	}
+
+	pe, err := (&MsgPkEnd{base{raw: []byte(pkEnd)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pe.PkID != 1020304 || pe.PkStatus != 401 || pe.PunishTopic != "惩罚：学猫叫" {
+		t.Errorf("PK_END = %+v", pe)
+	}
+
+	ps, err := (&MsgPkSettle{base{raw: []byte(pkSettle)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps.PkID != 1020304 || !ps.InitInfo.IsWinner || ps.InitInfo.InitID != 21852 || ps.MatchInfo.MatchID != 22000 ||
+		ps.MatchInfo.Votes != 100 || ps.BestUser.Uname != "一只鱼" || ps.BestUser.PrivilegeType != 3 {
+		t.Errorf("PK_SETTLE = %+v", ps)
+	}
+
+	me, err := (&MsgPkMicEnd{base{raw: []byte(pkMicEnd)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if me.PkID != 1020304 || me.PkStatus != 1000 {
+		t.Errorf("PK_MIC_END = %+v", me)
+	}
+
+	if _, err = (&MsgPkLotteryStart{base{raw: []byte(`{"cmd":"PK_LOTTERY_START","data":{"pk_id":"abc"}}`)}}).Parse(); err == nil {
+		t.Error("want error for invalid pk_id")
+	}
+	if _, err = (&MsgPkBattleEnd{base{raw: []byte(`{"cmd":"PK_BATTLE_END","pk_id":"abc","data":{}}`)}}).Parse(); err == nil {
+		t.Error("want error for invalid pk_id")
+	}
+}
+
+// 同一次连击的 SEND_GIFT 与 COMBO_SEND
+const (
+	sendGiftCombo = `{"cmd":"SEND_GIFT","data":{"action":"投喂","batch_combo_id":"batch:gift:combo_id:2920960:546195:31036:1651401000.1234","coin_type":"gold","giftId":31036,"giftName":"小花花","num":1,"price":100,"total_coin":100,"uid":2920960,"uname":"一只鱼","timestamp":1651401000}}`
+	comboSend     = `{"cmd":"COMBO_SEND","data":{"action":"投喂","batch_combo_id":"batch:gift:combo_id:2920960:546195:31036:1651401000.1234","batch_combo_num":5,"combo_id":"gift:combo_id:2920960:546195:31036:1651401000.1233","combo_num":5,"combo_total_coin":500,"dmscore":112,"gift_id":31036,"gift_name":"小花花","gift_num":0,"is_show":1,"medal_info":{"anchor_roomid":0,"anchor_uname":"","guard_level":0,"is_lighted":1,"medal_color":1725515,"medal_level":21,"medal_name":"鱼粉","target_id":546195},"name_color":"","r_uname":"老番茄","receive_user_info":{"uid":546195,"uname":"老番茄"},"ruid":546195,"send_master":null,"total_num":5,"uid":2920960,"uname":"一只鱼"}}`
+)
+
+func TestMsgGiftParse(t *testing.T) {
+	g, err := (&MsgSendGift{base{raw: []byte(sendGiftCombo)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := (&MsgComboSend{base{raw: []byte(comboSend)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ComboTotalCoin != 500 || c.TotalNum != 5 || c.RUID != 546195 || c.MedalInfo.MedalName != "鱼粉" {
+		t.Errorf("COMBO_SEND = %+v", c)
+	}
+	if !c.Matches(g) {
+		t.Error("COMBO_SEND should match its SEND_GIFT")
+	}
+	if c.Matches(&SendGift{}) || (&ComboSend{}).Matches(&SendGift{}) {
+		t.Error("empty batch_combo_id should never match")
+	}
+
+	sg, err := (&MsgSpecialGift{base{raw: []byte(`{"cmd":"SPECIAL_GIFT","data":{"39":{"id":"3218562145","time":90,"hadJoin":0,"num":1,"content":"前方高能预警","action":"start","storm_gif":"http://static.hdslb.com/live-static/live-room/images/gift-section/mobilegift/2/jiezou.gif"}}}`)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sg.GiftID != 39 || sg.Action != "start" || sg.Content != "前方高能预警" || sg.Time != 90 {
+		t.Errorf("SPECIAL_GIFT = %+v", sg)
+	}
+
+	sys, err := (&MsgSysGift{base{raw: []byte(`{"cmd":"SYS_GIFT","msg":"一只鱼:? 在直播间:?21852?:?赠送 小电视一个","msg_text":"一只鱼在直播间21852赠送小电视一个","roomid":21852,"real_roomid":21852,"giftId":25,"tv_id":"0","url":"https://live.bilibili.com/21852"}`)}}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sys.RealRoomID != 21852 || sys.GiftID != 25 || sys.MsgText == "" {
+		t.Errorf("SYS_GIFT = %+v", sys)
+	}
+}
@@ -0,0 +1,143 @@
+package live
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Option New 的可选参数
+type Option func(l *Live) error
+
+// New 创建一个新的直播连接。默认心跳间隔 30s，Rev 无缓存，不输出日志，不重连
+func New(opts ...Option) (*Live, error) {
+	l := &Live{
+		hb:       30 * time.Second,
+		entered:  make(chan struct{}),
+		protover: ProtoverZlib,
+		bp:       Backpressure{Policy: BackpressureDropNewest, Timeout: 5 * time.Second},
+	}
+	for _, opt := range opts {
+		if err := opt(l); err != nil {
+			return nil, err
+		}
+	}
+	if l.logger == nil {
+		l.logger = nopLogger{}
+	}
+	l.Rev = make(chan *Transport, l.cache)
+	return l, nil
+}
+
+// WithDebug 为 true 且没有通过 WithLogger 设置 Logger 时输出日志到 stdout，false 时不做任何改变
+func WithDebug(debug bool) Option {
+	return func(l *Live) error {
+		if debug && l.logger == nil {
+			// 经过 Live 的日志方法多一层调用，文件位置指向 Live 内部打印日志的地方
+			l.logger = &stdLogger{l: log.New(os.Stdout, "Live ", log.LstdFlags|log.Lshortfile), depth: 4}
+		}
+		return nil
+	}
+}
+
+// WithLogger 使用自定义 Logger，如 NewStdLogger、NewSlogLogger 的返回值
+func WithLogger(logger Logger) Option {
+	return func(l *Live) error {
+		if logger == nil {
+			logger = nopLogger{}
+		}
+		l.logger = logger
+		return nil
+	}
+}
+
+// WithHeartbeat 心跳包发送间隔，必须大于 0。不发送心跳包，70 秒之后会断开连接，通常每 30 秒发送 1 次
+func WithHeartbeat(d time.Duration) Option {
+	return func(l *Live) error {
+		if d <= 0 {
+			return fmt.Errorf("heartbeat interval must be positive: %s", d)
+		}
+		l.hb = d
+		return nil
+	}
+}
+
+// WithBuffer Rev channel 的缓存
+func WithBuffer(n int) Option {
+	return func(l *Live) error {
+		l.cache = n
+		return nil
+	}
+}
+
+// WithRecover panic recover 后的操作函数
+func WithRecover(f func(error)) Option {
+	return func(l *Live) error {
+		l.recover = f
+		return nil
+	}
+}
+
+// WithReconnect 断线重连策略，参见 SetReconnect
+func WithReconnect(p *ReconnectPolicy) Option {
+	return func(l *Live) error {
+		l.SetReconnect(p)
+		return nil
+	}
+}
+
+// WithProtover 进房时协商的协议版本，参见 SetProtover
+func WithProtover(v int) Option {
+	return func(l *Live) error {
+		return l.SetProtover(v)
+	}
+}
+
+// WithParseWorkers 并行解码消息的协程数，参见 SetParseWorkers
+func WithParseWorkers(n int) Option {
+	return func(l *Live) error {
+		l.SetParseWorkers(n)
+		return nil
+	}
+}
+
+// WithBackpressure Rev 的背压策略，参见 SetBackpressure
+func WithBackpressure(b Backpressure) Option {
+	return func(l *Live) error {
+		return l.SetBackpressure(b)
+	}
+}
+
+// WithFilter 只投递 f 返回 true 的消息，多个 filter 需全部通过。错误与 MsgReconnect 总是会投递
+func WithFilter(f func(Msg) bool) Option {
+	return func(l *Live) error {
+		l.filters = append(l.filters, f)
+		return nil
+	}
+}
+
+// WithCmds 只投递指定 cmd 的消息，如 WithCmds("DANMU_MSG", "SEND_GIFT")。错误与 MsgReconnect 总是会投递
+func WithCmds(cmds ...string) Option {
+	set := make(map[string]struct{}, len(cmds))
+	for _, c := range cmds {
+		set[c] = struct{}{}
+	}
+	return WithFilter(func(m Msg) bool {
+		_, ok := set[m.Cmd()]
+		return ok
+	})
+}
+
+// accept 库自身产生的 MsgReconnect 不经过 filter
+func (l *Live) accept(m Msg) bool {
+	if _, ok := m.(*MsgReconnect); ok {
+		return true
+	}
+	for _, f := range l.filters {
+		if !f(m) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package live
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	l, err := New(WithBuffer(3), WithHeartbeat(10*time.Second), WithProtover(ProtoverBrotli), WithReconnect(DefaultReconnectPolicy()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cap(l.Rev) != 3 || l.hb != 10*time.Second || l.protover != ProtoverBrotli || l.reconnect == nil {
+		t.Errorf("options not applied: %+v", l)
+	}
+	if _, err = New(WithProtover(1)); err == nil {
+		t.Error("want error for protover 1")
+	}
+	if _, err = New(WithBackpressure(Backpressure{Policy: -1})); err == nil {
+		t.Error("want error for unknown backpressure policy")
+	}
+	if _, err = New(WithHeartbeat(0)); err == nil {
+		t.Error("want error for zero heartbeat interval")
+	}
+}
+
+func TestWithCmds(t *testing.T) {
+	l, err := New(WithBuffer(10), WithCmds(cmdDanmaku))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := l.handle(packFrame(t, wsVerZlib, cmdSendGift, cmdDanmaku, cmdInteractWord))
+	// 重连后进房成功的帧先投递 MsgReconnect，不受 WithCmds 影响
+	f.entered = true
+	l.rc = &MsgReconnect{Attempt: 1}
+	go func() { <-l.entered }()
+	l.deliver(context.Background(), f)
+	if len(l.Rev) != 2 {
+		t.Fatalf("got %d msgs, want 2", len(l.Rev))
+	}
+	for _, want := range []string{cmdReconnect, cmdDanmaku} {
+		if tp := <-l.Rev; tp.Msg.Cmd() != want {
+			t.Errorf("got %s, want %s", tp.Msg.Cmd(), want)
+		}
+	}
+}
+
+func TestWithDebug(t *testing.T) {
+	logger := NewStdLogger(nil)
+	for _, opts := range [][]Option{
+		{WithLogger(logger), WithDebug(false)},
+		{WithLogger(logger), WithDebug(true)},
+		{WithDebug(true), WithLogger(logger)},
+	} {
+		l, err := New(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.logger != logger {
+			t.Errorf("logger = %T, want the one from WithLogger", l.logger)
+		}
+	}
+	if l, _ := New(WithDebug(false)); l.logger != (nopLogger{}) {
+		t.Errorf("logger = %T, want nopLogger", l.logger)
+	}
+}
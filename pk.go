@@ -0,0 +1,295 @@
+package live
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PkContributor 本方助攻
+type PkContributor struct {
+	UID   int64
+	Uname string
+	Face  string
+	Score int64
+}
+
+// PkState 当前大乱斗的状态，由 PKTracker 维护
+type PkState struct {
+	PkID       int64
+	PkStatus   int // 参见 PkHeader.PkStatus
+	BattleType int
+
+	Room          int64 // 本方直播间
+	OpponentRoom  int64 // 对面直播间
+	OpponentUID   int64 // 对面主播，仅收到 PK_BATTLE_PRE 或 PK_MATCH_INFO 后有值
+	OpponentUname string
+
+	Votes         int64 // 本方乱斗值
+	OpponentVotes int64 // 对面乱斗值
+	BestUname     string
+	OpponentBest  string
+
+	StartTime  int64 // unix 秒，PK开始
+	FrozenTime int64 // unix 秒，停止计票
+	EndTime    int64 // unix 秒，惩罚结束
+
+	Ending          bool            // 已收到 PK_ENDING，处于最后几秒
+	Ended           bool            // 已收到 PK_BATTLE_END
+	Result          int             // 本方结果，参见 PkResultWin，结束前为 0
+	TopContributors []PkContributor // 本方助攻榜，结算时下发
+}
+
+// Remaining 距离停止计票的剩余时间，未开始或已结束时为 0
+func (s *PkState) Remaining(now time.Time) time.Duration {
+	if s.FrozenTime == 0 || s.Ended {
+		return 0
+	}
+	if d := time.Unix(s.FrozenTime, 0).Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// PkSummary 一场大乱斗结算后的汇总
+type PkSummary struct {
+	PkID            int64
+	Room            int64 // 本方直播间
+	OpponentRoom    int64
+	OpponentUname   string
+	Votes           int64
+	OpponentVotes   int64
+	Result          int // 本方结果，参见 PkResultWin
+	TopContributors []PkContributor
+	Settle          *PkBattleSettleV2 // 原始的结算信息
+}
+
+// PKTracker 根据 PK_* 消息维护当前大乱斗的状态，并在结算时给出汇总
+type PKTracker struct {
+	mu       sync.Mutex
+	room     int64
+	state    *PkState
+	settled  int64 // 最近一次已结算的 PkID
+	onUpdate func(*PkState)
+	onSettle func(*PkSummary)
+}
+
+// NewPKTracker room 为本方直播间的真实ID，用于区分 init_info 与 match_info。
+// 为 0 时认为 init_info 是本方
+func NewPKTracker(room int64) *PKTracker {
+	return &PKTracker{room: room}
+}
+
+// OnUpdate 状态变化，参数为状态的副本
+func (t *PKTracker) OnUpdate(f func(*PkState)) {
+	t.mu.Lock()
+	t.onUpdate = f
+	t.mu.Unlock()
+}
+
+// OnSettle 大乱斗结算，以 PK_BATTLE_SETTLE_V2 为准，每场只触发一次
+func (t *PKTracker) OnSettle(f func(*PkSummary)) {
+	t.mu.Lock()
+	t.onSettle = f
+	t.mu.Unlock()
+}
+
+// State 当前大乱斗状态的副本，没有进行中的大乱斗时返回 nil
+func (t *PKTracker) State() *PkState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.copyState()
+}
+
+// Register 注册 PK 相关 cmd
+func (t *PKTracker) Register(d *Dispatcher) {
+	for _, cmd := range []string{cmdPkBattlePre, cmdPkBattleStart, cmdPkBattleProcess, cmdPkEnding,
+		cmdPkBattleEnd, cmdPkBattleSettleUser, cmdPkBattleSettleV2, cmdPkMatchInfo} {
+		d.on(cmd, t.Handle)
+	}
+}
+
+// Handle 处理一条消息，非 PK 消息会被忽略
+func (t *PKTracker) Handle(m Msg) error {
+	var (
+		s   *PkState
+		sum *PkSummary
+		err error
+	)
+	t.mu.Lock()
+	switch m := m.(type) {
+	case *MsgPkBattlePre:
+		s, err = t.pre(m)
+	case *MsgPkMatchInfo:
+		s, err = t.matchInfo(m)
+	case *MsgPkBattleStart:
+		s, err = t.start(m)
+	case *MsgPkBattleProcess:
+		s, err = t.process(m)
+	case *MsgPkEnding:
+		s, err = t.ending(m)
+	case *MsgPkBattleEnd:
+		s, err = t.end(m)
+	case *MsgPkBattleSettleUser:
+		s, err = t.settleUser(m)
+	case *MsgPkBattleSettleV2:
+		s, sum, err = t.settle(m)
+	default:
+		t.mu.Unlock()
+		return nil
+	}
+	onUpdate, onSettle := t.onUpdate, t.onSettle
+	t.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", m.Cmd(), err)
+	}
+	if s != nil && onUpdate != nil {
+		onUpdate(s)
+	}
+	if sum != nil && onSettle != nil {
+		onSettle(sum)
+	}
+	return nil
+}
+
+// battle 返回 id 对应的状态，新的一场会替换旧的状态
+func (t *PKTracker) battle(h PkHeader) *PkState {
+	if t.state == nil || (h.PkID != 0 && t.state.PkID != h.PkID) {
+		t.state = &PkState{PkID: h.PkID}
+	}
+	if h.PkStatus != 0 {
+		t.state.PkStatus = h.PkStatus
+	}
+	return t.state
+}
+
+// sides 按本方直播间区分双方，init_info 为发起方，本直播间也可能是 match_info
+func (t *PKTracker) sides(init, match PkRoom) (our, their PkRoom) {
+	if t.room != 0 && match.RoomID == t.room {
+		return match, init
+	}
+	return init, match
+}
+
+func (t *PKTracker) copyState() *PkState {
+	if t.state == nil {
+		return nil
+	}
+	s := *t.state
+	s.TopContributors = append([]PkContributor(nil), t.state.TopContributors...)
+	return &s
+}
+
+func (t *PKTracker) pre(m *MsgPkBattlePre) (*PkState, error) {
+	p, err := m.Parse()
+	if err != nil {
+		return nil, err
+	}
+	s := t.battle(p.PkHeader)
+	s.BattleType = p.BattleType
+	s.OpponentRoom, s.OpponentUID, s.OpponentUname = p.RoomID, p.UID, p.Uname
+	return t.copyState(), nil
+}
+
+func (t *PKTracker) matchInfo(m *MsgPkMatchInfo) (*PkState, error) {
+	p, err := m.Parse()
+	if err != nil {
+		return nil, err
+	}
+	s := t.battle(p.PkHeader)
+	s.OpponentRoom, s.OpponentUID, s.OpponentUname = p.RoomID, p.UID, p.Uname
+	return t.copyState(), nil
+}
+
+func (t *PKTracker) start(m *MsgPkBattleStart) (*PkState, error) {
+	p, err := m.Parse()
+	if err != nil {
+		return nil, err
+	}
+	s := t.battle(p.PkHeader)
+	s.BattleType = p.BattleType
+	s.StartTime, s.FrozenTime, s.EndTime = p.StartTime, p.FrozenTime, p.EndTime
+	our, their := t.sides(PkRoom{RoomID: p.InitInfo.RoomID}, PkRoom{RoomID: p.MatchInfo.RoomID})
+	s.Room, s.OpponentRoom = our.RoomID, their.RoomID
+	return t.copyState(), nil
+}
+
+func (t *PKTracker) process(m *MsgPkBattleProcess) (*PkState, error) {
+	p, err := m.Parse()
+	if err != nil {
+		return nil, err
+	}
+	s := t.battle(p.PkHeader)
+	our, their := t.sides(p.InitInfo, p.MatchInfo)
+	s.Room, s.OpponentRoom = our.RoomID, their.RoomID
+	s.Votes, s.OpponentVotes = our.Votes, their.Votes
+	s.BestUname, s.OpponentBest = our.BestUname, their.BestUname
+	return t.copyState(), nil
+}
+
+func (t *PKTracker) ending(m *MsgPkEnding) (*PkState, error) {
+	h, err := m.GetHeader()
+	if err != nil {
+		return nil, err
+	}
+	t.battle(*h).Ending = true
+	return t.copyState(), nil
+}
+
+func (t *PKTracker) end(m *MsgPkBattleEnd) (*PkState, error) {
+	p, err := m.Parse()
+	if err != nil {
+		return nil, err
+	}
+	s := t.battle(p.PkHeader)
+	our, their := t.sides(p.InitInfo, p.MatchInfo)
+	s.Room, s.OpponentRoom = our.RoomID, their.RoomID
+	s.Votes, s.OpponentVotes = our.Votes, their.Votes
+	s.BestUname, s.OpponentBest = our.BestUname, their.BestUname
+	s.Ended, s.Result = true, our.WinnerType
+	return t.copyState(), nil
+}
+
+func (t *PKTracker) settleUser(m *MsgPkBattleSettleUser) (*PkState, error) {
+	p, err := m.Parse()
+	if err != nil {
+		return nil, err
+	}
+	s := t.battle(p.PkHeader)
+	s.Result = p.ResultType
+	return t.copyState(), nil
+}
+
+func (t *PKTracker) settle(m *MsgPkBattleSettleV2) (*PkState, *PkSummary, error) {
+	p, err := m.Parse()
+	if err != nil {
+		return nil, nil, err
+	}
+	s := t.battle(p.PkHeader)
+	s.Ended, s.Result = true, p.ResultType
+	if p.ResultInfo.PkVotes != 0 {
+		s.Votes = p.ResultInfo.PkVotes
+	}
+	s.TopContributors = s.TopContributors[:0]
+	for _, a := range p.AssistList {
+		s.TopContributors = append(s.TopContributors, PkContributor{UID: a.ID, Uname: a.Uname, Face: a.Face, Score: a.Score})
+	}
+	if t.settled == s.PkID {
+		return t.copyState(), nil, nil
+	}
+	t.settled = s.PkID
+
+	sum := &PkSummary{
+		PkID:            s.PkID,
+		Room:            s.Room,
+		OpponentRoom:    s.OpponentRoom,
+		OpponentUname:   s.OpponentUname,
+		Votes:           s.Votes,
+		OpponentVotes:   s.OpponentVotes,
+		Result:          s.Result,
+		TopContributors: append([]PkContributor(nil), s.TopContributors...),
+		Settle:          p,
+	}
+	return t.copyState(), sum, nil
+}
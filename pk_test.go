@@ -0,0 +1,105 @@
+package live
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPKTracker(t *testing.T) {
+	const (
+		start  = `{"cmd":"PK_BATTLE_START","pk_id":300458312,"pk_status":201,"timestamp":1651400010,"data":{"battle_type":1,"pk_start_time":1651400010,"pk_frozen_time":1651400310,"pk_end_time":1651400320,"pk_votes_name":"乱斗值","init_info":{"room_id":21852},"match_info":{"room_id":22000}}}`
+		ending = `{"cmd":"PK_ENDING","pk_id":300458312,"pk_status":201,"timestamp":1651400305,"data":{}}`
+	)
+
+	tr := NewPKTracker(21852)
+	d := newTrackerDispatcher(t, tr)
+	var (
+		updates int
+		sums    []*PkSummary
+	)
+	tr.OnUpdate(func(*PkState) { updates++ })
+	tr.OnSettle(func(s *PkSummary) { sums = append(sums, s) })
+
+	if tr.State() != nil {
+		t.Fatal("want nil state before any PK")
+	}
+	d.DispatchMsg(cmdMsg(cmdPkBattlePre, pkBattlePre))
+	d.DispatchMsg(cmdMsg(cmdPkBattleStart, start))
+	d.DispatchMsg(cmdMsg(cmdPkBattleProcess, pkBattleProcess))
+
+	s := tr.State()
+	if s.PkID != 300458312 || s.Room != 21852 || s.OpponentRoom != 22000 || s.Votes != 520 || s.OpponentVotes != 100 {
+		t.Errorf("state = %+v", s)
+	}
+	if r := s.Remaining(time.Unix(1651400250, 0)); r != time.Minute {
+		t.Errorf("Remaining = %s, want 1m", r)
+	}
+
+	d.DispatchMsg(cmdMsg(cmdPkEnding, ending))
+	d.DispatchMsg(cmdMsg(cmdPkBattleEnd, pkBattleEnd))
+	if s = tr.State(); !s.Ending || !s.Ended || s.Result != PkResultWin || s.Remaining(time.Unix(1651400250, 0)) != 0 {
+		t.Errorf("state after end = %+v", s)
+	}
+
+	d.DispatchMsg(cmdMsg(cmdPkBattleSettleV2, pkBattleSettleV2))
+	d.DispatchMsg(cmdMsg(cmdPkBattleSettleV2, pkBattleSettleV2))
+	if updates != 7 {
+		t.Errorf("updates = %d, want 7", updates)
+	}
+	if len(sums) != 1 {
+		t.Fatalf("summaries = %d, want 1", len(sums))
+	}
+	if sum := sums[0]; sum.PkID != 300458312 || sum.Room != 21852 || sum.Votes != 1314 || sum.Result != PkResultWin ||
+		len(sum.TopContributors) != 2 || sum.TopContributors[0].UID != 2920960 || sum.Settle == nil {
+		t.Errorf("summary = %+v", sum)
+	}
+}
+
+func TestPKTrackerMatchSide(t *testing.T) {
+	// 本方为 match_info 一侧时双方需要对调，结果取 match_info 的 winner_type
+	tr := NewPKTracker(22000)
+	for _, raw := range [][2]string{{cmdPkBattleProcess, pkBattleProcess}, {cmdPkBattleEnd, pkBattleEnd}} {
+		if err := tr.Handle(cmdMsg(raw[0], raw[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := tr.State(); s.Room != 22000 || s.OpponentRoom != 21852 || s.Votes != 100 || s.OpponentVotes != 1314 ||
+		s.BestUname != "路人" || s.Result != PkResultLose {
+		t.Errorf("state = %+v", s)
+	}
+}
+
+func TestPKTrackerMidway(t *testing.T) {
+	// 中途开始监听，只收到结算时也给出汇总，且同一场只给出一次
+	tr := NewPKTracker(21852)
+	var sums []*PkSummary
+	tr.OnSettle(func(s *PkSummary) { sums = append(sums, s) })
+	for i := 0; i < 2; i++ {
+		if err := tr.Handle(cmdMsg(cmdPkBattleSettleV2, pkBattleSettleV2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sums) != 1 || sums[0].PkID != 300458312 || sums[0].Votes != 1314 || sums[0].Result != PkResultWin {
+		t.Fatalf("summaries = %+v", sums)
+	}
+	if s := tr.State(); !s.Ended || s.Remaining(time.Unix(1651400250, 0)) != 0 {
+		t.Errorf("state = %+v", s)
+	}
+}
+
+func TestPKTrackerNextBattle(t *testing.T) {
+	tr := NewPKTracker(21852)
+	for _, raw := range [][2]string{
+		{cmdPkBattleProcess, pkBattleProcess},
+		{cmdPkBattleSettleV2, pkBattleSettleV2},
+		// 新的一场会替换旧的状态
+		{cmdPkBattlePre, `{"cmd":"PK_BATTLE_PRE","pk_id":300458313,"pk_status":101,"data":{"room_id":23000}}`},
+	} {
+		if err := tr.Handle(cmdMsg(raw[0], raw[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := tr.State(); s.PkID != 300458313 || s.OpponentRoom != 23000 || s.Votes != 0 || s.Ended || len(s.TopContributors) != 0 {
+		t.Errorf("state of next PK = %+v", s)
+	}
+}
@@ -0,0 +1,266 @@
+package live
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RankUser 高能榜用户
+type RankUser struct {
+	UID        int64
+	Uname      string
+	Face       string
+	Score      int64
+	Rank       int
+	GuardLevel int
+}
+
+// RoomSnapshot 直播间数据的快照，各字段的 At 为最近一次收到对应消息的时间，未收到时为零值
+type RoomSnapshot struct {
+	Time time.Time // 最近一次变化的时间
+
+	Hot   int // 心跳回应的人气值
+	HotAt time.Time
+
+	Watched   int // 看过人数
+	WatchedAt time.Time
+
+	Fans     int
+	FansClub int // 粉丝团人数
+	FansAt   time.Time
+
+	RankCount   int // 高能榜人数
+	RankCountAt time.Time
+
+	Rank     []RankUser // 高能榜
+	RankType string
+	RankAt   time.Time
+
+	Top3   []string // 高能榜前三的提示
+	Top3At time.Time
+}
+
+func (s *RoomSnapshot) clone() *RoomSnapshot {
+	c := *s
+	c.Rank = append([]RankUser(nil), s.Rank...)
+	c.Top3 = append([]string(nil), s.Top3...)
+	return &c
+}
+
+// RoomStats 将人气、看过人数、粉丝数、高能榜等消息合并为一个快照，
+// 快照变化时通知订阅者，并在内存中保留最近 size 个快照
+type RoomStats struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	cur     *RoomSnapshot
+	history []*RoomSnapshot // 环形缓冲
+	next    int
+	full    bool
+	subs    map[int]func(prev, cur *RoomSnapshot)
+	subID   int
+}
+
+// NewRoomStats size 为保留的快照数量，<=0 时为 1024。now 为时间来源，nil 时使用 time.Now
+func NewRoomStats(size int, now func() time.Time) *RoomStats {
+	if size <= 0 {
+		size = 1024
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &RoomStats{
+		now:     now,
+		cur:     &RoomSnapshot{},
+		history: make([]*RoomSnapshot, size),
+		subs:    make(map[int]func(prev, cur *RoomSnapshot)),
+	}
+}
+
+// Subscribe 快照变化时调用 f，prev、cur 均为副本。返回的函数用于取消订阅
+func (r *RoomStats) Subscribe(f func(prev, cur *RoomSnapshot)) (cancel func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subID++
+	id := r.subID
+	r.subs[id] = f
+	return func() {
+		r.mu.Lock()
+		delete(r.subs, id)
+		r.mu.Unlock()
+	}
+}
+
+// Snapshot 当前快照的副本
+func (r *RoomStats) Snapshot() *RoomSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cur.clone()
+}
+
+// History 保留的快照，从旧到新
+func (r *RoomStats) History() []*RoomSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var h []*RoomSnapshot
+	if r.full {
+		h = append(h, r.history[r.next:]...)
+	}
+	h = append(h, r.history[:r.next]...)
+	for i := range h {
+		h[i] = h[i].clone()
+	}
+	return h
+}
+
+// Register 注册人气、看过人数、粉丝数与在线榜相关 cmd
+func (r *RoomStats) Register(d *Dispatcher) {
+	for _, cmd := range []string{cmdHeartbeatReply, cmdWatChedChange, cmdRoomRealTimeMessageUpdate,
+		cmdOnlineRankCount, cmdOnlineRankV2, cmdOnlineRankTop3} {
+		d.on(cmd, r.Handle)
+	}
+}
+
+// Handle 处理一条消息，无关的消息会被忽略
+func (r *RoomStats) Handle(m Msg) error {
+	var (
+		update func(s *RoomSnapshot, now time.Time) bool
+		err    error
+	)
+	switch m := m.(type) {
+	case *MsgHeartbeatReply:
+		if len(m.Raw()) < 4 {
+			err = fmt.Errorf("invalid length %d", len(m.Raw()))
+			break
+		}
+		hot := m.GetHot()
+		update = func(s *RoomSnapshot, now time.Time) bool {
+			s.HotAt = now
+			return setInt(&s.Hot, hot)
+		}
+	case *MsgWatChed:
+		var w *WatChed
+		if w, err = m.Parse(); err == nil {
+			update = func(s *RoomSnapshot, now time.Time) bool {
+				s.WatchedAt = now
+				return setInt(&s.Watched, w.Num)
+			}
+		}
+	case *MsgFansUpdate:
+		var f *FansUpdate
+		if f, err = m.Parse(); err == nil {
+			update = func(s *RoomSnapshot, now time.Time) bool {
+				s.FansAt = now
+				a, b := setInt(&s.Fans, f.Fans), setInt(&s.FansClub, f.FansClub)
+				return a || b
+			}
+		}
+	case *MsgOnlineRankCount:
+		n := m.GetCount()
+		if n < 0 {
+			err = fmt.Errorf("invalid data")
+			break
+		}
+		update = func(s *RoomSnapshot, now time.Time) bool {
+			s.RankCountAt = now
+			return setInt(&s.RankCount, n)
+		}
+	case *MsgOnlineRankV2:
+		var o *OnlineRankV2
+		if o, err = m.Parse(); err == nil {
+			rank := make([]RankUser, 0, len(o.List))
+			for _, u := range o.List {
+				score, _ := strconv.ParseInt(u.Score, 10, 64)
+				rank = append(rank, RankUser{UID: u.UID, Uname: u.Uname, Face: u.Face, Score: score, Rank: u.Rank, GuardLevel: u.GuardLevel})
+			}
+			update = func(s *RoomSnapshot, now time.Time) bool {
+				s.RankAt = now
+				changed := s.RankType != o.RankType || !equalRank(s.Rank, rank)
+				s.Rank, s.RankType = rank, o.RankType
+				return changed
+			}
+		}
+	case *MsgOnlineRankTop3:
+		var o *OnlineRankTop3
+		if o, err = m.Parse(); err == nil {
+			var top3 []string
+			for _, t := range o.List {
+				top3 = append(top3, t.Msg)
+			}
+			update = func(s *RoomSnapshot, now time.Time) bool {
+				s.Top3At = now
+				changed := !equalStrings(s.Top3, top3)
+				s.Top3 = top3
+				return changed
+			}
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", m.Cmd(), err)
+	}
+	r.apply(update)
+	return nil
+}
+
+func (r *RoomStats) apply(update func(s *RoomSnapshot, now time.Time) bool) {
+	r.mu.Lock()
+	now := r.now()
+	prev := r.cur.clone()
+	if !update(r.cur, now) {
+		r.mu.Unlock()
+		return
+	}
+	r.cur.Time = now
+	r.history[r.next] = r.cur.clone()
+	r.next = (r.next + 1) % len(r.history)
+	if r.next == 0 {
+		r.full = true
+	}
+	cur := r.cur.clone()
+	subs := make([]func(prev, cur *RoomSnapshot), 0, len(r.subs))
+	for i := 1; i <= r.subID; i++ {
+		if f, ok := r.subs[i]; ok {
+			subs = append(subs, f)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, f := range subs {
+		f(prev, cur)
+	}
+}
+
+func setInt(p *int, v int) bool {
+	if *p == v {
+		return false
+	}
+	*p = v
+	return true
+}
+
+func equalRank(a, b []RankUser) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package live
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestRoomStats(t *testing.T) {
+	hot := make([]byte, 4)
+	binary.BigEndian.PutUint32(hot, 12345)
+
+	now := time.Unix(1651400000, 0)
+	r := NewRoomStats(3, func() time.Time { return now })
+	var changes int
+	cancel := r.Subscribe(func(prev, cur *RoomSnapshot) {
+		changes++
+		if !cur.Time.Equal(now) || prev.Time.Equal(now) {
+			t.Errorf("prev.Time = %s, cur.Time = %s", prev.Time, cur.Time)
+		}
+	})
+	d := newTrackerDispatcher(t, r)
+
+	for _, m := range []Msg{
+		&MsgHeartbeatReply{base{raw: hot}},
+		&MsgHeartbeatReply{base{raw: hot}}, // 没有变化
+		cmdMsg(cmdWatChedChange, `{"cmd":"WATCHED_CHANGE","data":{"num":144450,"text_large":"14.4万人看过","text_small":"14.4万"}}`),
+		cmdMsg(cmdRoomRealTimeMessageUpdate, `{"cmd":"ROOM_REAL_TIME_MESSAGE_UPDATE","data":{"roomid":21852,"fans":1384297,"fans_club":49182,"red_notice":-1}}`),
+		cmdMsg(cmdOnlineRankCount, `{"cmd":"ONLINE_RANK_COUNT","data":{"count":520}}`),
+		cmdMsg(cmdOnlineRankV2, `{"cmd":"ONLINE_RANK_V2","data":{"list":[{"uid":2920960,"face":"","score":"1314","uname":"一只鱼","rank":1,"guard_level":3}],"rank_type":"gold-rank"}}`),
+		cmdMsg(cmdOnlineRankTop3, `{"cmd":"ONLINE_RANK_TOP3","data":{"dmscore":112,"list":[{"msg":"恭喜 <%一只鱼%> 成为高能榜","rank":1}]}}`),
+	} {
+		now = now.Add(time.Second)
+		d.DispatchMsg(m)
+	}
+
+	s := r.Snapshot()
+	if s.Hot != 12345 || s.Watched != 144450 || s.Fans != 1384297 || s.FansClub != 49182 || s.RankCount != 520 ||
+		len(s.Rank) != 1 || s.Rank[0].Score != 1314 || s.RankType != "gold-rank" || len(s.Top3) != 1 {
+		t.Errorf("snapshot = %+v", s)
+	}
+	// 第二个心跳没有变化，但会更新时间
+	if s.HotAt.Unix() != 1651400002 || s.Time.Unix() != 1651400007 {
+		t.Errorf("HotAt = %d, Time = %d", s.HotAt.Unix(), s.Time.Unix())
+	}
+	if changes != 6 {
+		t.Errorf("changes = %d, want 6", changes)
+	}
+
+	h := r.History()
+	if len(h) != 3 || h[0].RankCount != 520 || len(h[0].Rank) != 0 || h[2].Time.Unix() != 1651400007 {
+		t.Errorf("history = %+v", h)
+	}
+
+	cancel()
+	now = now.Add(time.Second)
+	d.DispatchMsg(cmdMsg(cmdOnlineRankCount, `{"cmd":"ONLINE_RANK_COUNT","data":{"count":521}}`))
+	if changes != 6 {
+		t.Errorf("changes = %d after cancel, want 6", changes)
+	}
+}
+
+func TestRoomStatsEdgeCases(t *testing.T) {
+	r := NewRoomStats(0, nil)
+	var changes int
+	var cancel func()
+	// 在回调中取消订阅不会死锁，之后不再收到通知
+	cancel = r.Subscribe(func(prev, cur *RoomSnapshot) {
+		changes++
+		cancel()
+	})
+
+	// 长度不足 4 字节的心跳回应报告错误，不改变快照
+	if err := r.Handle(&MsgHeartbeatReply{base{raw: []byte{0, 1}}}); err == nil {
+		t.Error("want error for short heartbeat reply")
+	}
+	if len(r.History()) != 0 {
+		t.Errorf("history = %+v", r.History())
+	}
+
+	// 相同的高能榜只记录一次
+	const rank = `{"cmd":"ONLINE_RANK_V2","data":{"list":[{"uid":1,"score":"10","uname":"u1","rank":1}],"rank_type":"gold-rank"}}`
+	for i := 0; i < 2; i++ {
+		if err := r.Handle(cmdMsg(cmdOnlineRankV2, rank)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if h := r.History(); len(h) != 1 || h[0].Rank[0].Score != 10 {
+		t.Errorf("history = %+v", h)
+	}
+	if err := r.Handle(cmdMsg(cmdOnlineRankCount, `{"cmd":"ONLINE_RANK_COUNT","data":{"count":1}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if changes != 1 {
+		t.Errorf("changes = %d, want 1", changes)
+	}
+}
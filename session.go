@@ -0,0 +1,210 @@
+package live
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type SessionEventType int
+
+const (
+	StreamStarted SessionEventType = iota + 1 // 开播
+	StreamEnded                               // 下播
+	StreamCutOff                              // 被超管切断，之后不会再触发 StreamEnded
+	TitleChanged                              // 标题改变
+	AreaChanged                               // 分区改变
+)
+
+func (t SessionEventType) String() string {
+	switch t {
+	case StreamStarted:
+		return "started"
+	case StreamEnded:
+		return "ended"
+	case StreamCutOff:
+		return "cut_off"
+	case TitleChanged:
+		return "title_changed"
+	case AreaChanged:
+		return "area_changed"
+	}
+	return "unknown"
+}
+
+// SessionArea 直播分区
+type SessionArea struct {
+	ID         int
+	Name       string
+	ParentID   int
+	ParentName string
+}
+
+// SessionEvent 直播状态的变化
+type SessionEvent struct {
+	Type    SessionEventType
+	RoomID  int64
+	LiveKey string    // 本场直播的标识，StreamStarted 时可能为空
+	Time    time.Time // StreamStarted 时优先使用开播时间
+	Reason  string    // 仅 StreamCutOff，切断原因
+
+	Title, OldTitle string      // 仅 TitleChanged
+	Area, OldArea   SessionArea // 仅 AreaChanged
+}
+
+type sessionStatus int
+
+const (
+	sessionUnknown sessionStatus = iota
+	sessionLive
+	sessionOffline
+)
+
+// SessionTracker 根据 LIVE、PREPARING、CUT_OFF、ROOM_CHANGE 判断开播与下播，
+// 开播时重复下发的 LIVE 只触发一次 StreamStarted
+type SessionTracker struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	status  sessionStatus
+	liveKey string
+	title   string
+	area    SessionArea
+	onEvent func(*SessionEvent)
+}
+
+// NewSessionTracker now 为时间来源，nil 时使用 time.Now
+func NewSessionTracker(now func() time.Time) *SessionTracker {
+	if now == nil {
+		now = time.Now
+	}
+	return &SessionTracker{now: now}
+}
+
+// Init 使用 ResolveRoom 得到的房间信息初始化状态，避免连接时已在直播的房间再次触发 StreamStarted。
+// 未调用 Init 时，收到 LIVE 之前的下播与切断不会触发事件
+func (t *SessionTracker) Init(info *RoomInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status = sessionOffline
+	if info.IsLive() {
+		t.status = sessionLive
+	}
+	t.title = info.Title
+	t.area = SessionArea{ID: info.AreaID, Name: info.AreaName, ParentID: info.ParentAreaID, ParentName: info.ParentAreaName}
+}
+
+// OnEvent 直播状态的变化
+func (t *SessionTracker) OnEvent(f func(*SessionEvent)) {
+	t.mu.Lock()
+	t.onEvent = f
+	t.mu.Unlock()
+}
+
+// Live 是否正在直播，未知时为 false
+func (t *SessionTracker) Live() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status == sessionLive
+}
+
+// Register 注册开播、下播、切断与房间信息变更
+func (t *SessionTracker) Register(d *Dispatcher) {
+	for _, cmd := range []string{cmdLive, cmdPreparing, cmdCutOff, cmdRoomChange} {
+		d.on(cmd, t.Handle)
+	}
+}
+
+// Handle 处理一条消息，无关的消息会被忽略
+func (t *SessionTracker) Handle(m Msg) error {
+	var (
+		evs []*SessionEvent
+		err error
+	)
+	switch m := m.(type) {
+	case *MsgLive:
+		var l *LiveStart
+		if l, err = m.Parse(); err == nil {
+			evs = t.live(l)
+		}
+	case *MsgPreparing:
+		var p *Preparing
+		if p, err = m.Parse(); err == nil {
+			evs = t.end(p.RoomID, StreamEnded, "")
+		}
+	case *MsgCutOff:
+		var c *CutOff
+		if c, err = m.Parse(); err == nil {
+			evs = t.end(c.RoomID, StreamCutOff, c.Msg)
+		}
+	case *MsgRoomChange:
+		var c *RoomChange
+		if c, err = m.Parse(); err == nil {
+			evs = t.change(c)
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", m.Cmd(), err)
+	}
+
+	t.mu.Lock()
+	f := t.onEvent
+	t.mu.Unlock()
+	if f != nil {
+		for _, ev := range evs {
+			f(ev)
+		}
+	}
+	return nil
+}
+
+func (t *SessionTracker) live(l *LiveStart) []*SessionEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status == sessionLive {
+		// 同一场直播重复的 LIVE，或只有 roomid 的 LIVE
+		if l.LiveKey == "" || t.liveKey == "" || l.LiveKey == t.liveKey {
+			if t.liveKey == "" {
+				t.liveKey = l.LiveKey
+			}
+			return nil
+		}
+	}
+	t.status, t.liveKey = sessionLive, l.LiveKey
+	ts := t.now()
+	if l.LiveTime != 0 {
+		ts = time.Unix(l.LiveTime, 0)
+	}
+	return []*SessionEvent{{Type: StreamStarted, RoomID: l.RoomID, LiveKey: l.LiveKey, Time: ts}}
+}
+
+func (t *SessionTracker) end(room int64, typ SessionEventType, reason string) []*SessionEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// 只有开播后才触发，切断后还会收到 PREPARING，未 Init 时也不知道是否在直播
+	live := t.status == sessionLive
+	ev := &SessionEvent{Type: typ, RoomID: room, LiveKey: t.liveKey, Time: t.now(), Reason: reason}
+	t.status, t.liveKey = sessionOffline, ""
+	if !live {
+		return nil
+	}
+	return []*SessionEvent{ev}
+}
+
+func (t *SessionTracker) change(c *RoomChange) []*SessionEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var evs []*SessionEvent
+	now := t.now()
+	if c.Title != "" && c.Title != t.title {
+		evs = append(evs, &SessionEvent{Type: TitleChanged, LiveKey: t.liveKey, Time: now, Title: c.Title, OldTitle: t.title})
+		t.title = c.Title
+	}
+	area := SessionArea{ID: c.AreaID, Name: c.AreaName, ParentID: c.ParentAreaID, ParentName: c.ParentAreaName}
+	if area.ID != 0 && area != t.area {
+		evs = append(evs, &SessionEvent{Type: AreaChanged, LiveKey: t.liveKey, Time: now, Area: area, OldArea: t.area})
+		t.area = area
+	}
+	return evs
+}
@@ -0,0 +1,109 @@
+package live
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionTracker(t *testing.T) {
+	now := time.Unix(1651400000, 0)
+	tr := NewSessionTracker(func() time.Time { return now })
+	tr.Init(&RoomInfo{RoomID: 21852, LiveStatus: LiveStatusPreparing, Title: "旧标题", AreaID: 371, AreaName: "虚拟主播"})
+	var evs []*SessionEvent
+	tr.OnEvent(func(ev *SessionEvent) { evs = append(evs, ev) })
+	d := newTrackerDispatcher(t, tr)
+
+	for _, m := range []Msg{
+		// 开播时重复下发的 LIVE
+		cmdMsg(cmdLive, `{"cmd":"LIVE","live_key":"223461829521467424","voice_background":"","sub_session_key":"223461829521467424sub_time:1651400000","live_platform":"pc","live_model":0,"roomid":21852,"live_time":1651399990}`),
+		cmdMsg(cmdLive, `{"cmd":"LIVE","roomid":"21852"}`),
+		cmdMsg(cmdLive, `{"cmd":"LIVE","live_key":"223461829521467424","live_platform":"pc","roomid":21852}`),
+		cmdMsg(cmdRoomChange, `{"cmd":"ROOM_CHANGE","data":{"title":"新标题","area_id":371,"parent_area_id":9,"area_name":"虚拟主播","parent_area_name":"虚拟主播","live_key":"223461829521467424","sub_session_key":""}}`),
+		cmdMsg(cmdCutOff, `{"cmd":"CUT_OFF","msg":"违反直播规范","roomid":"21852"}`),
+		cmdMsg(cmdPreparing, `{"cmd":"PREPARING","roomid":"21852"}`),
+		cmdMsg(cmdLive, `{"cmd":"LIVE","live_key":"223461829521467500","roomid":21852}`),
+		cmdMsg(cmdPreparing, `{"cmd":"PREPARING","roomid":"21852","round":1}`),
+	} {
+		d.DispatchMsg(m)
+	}
+
+	var types []string
+	for _, ev := range evs {
+		types = append(types, ev.Type.String())
+	}
+	if got := strings.Join(types, ","); got != "started,title_changed,area_changed,cut_off,started,ended" {
+		t.Fatalf("events = %s", got)
+	}
+	if ev := evs[0]; ev.RoomID != 21852 || ev.LiveKey != "223461829521467424" || ev.Time.Unix() != 1651399990 {
+		t.Errorf("started = %+v", ev)
+	}
+	if ev := evs[1]; ev.Title != "新标题" || ev.OldTitle != "旧标题" {
+		t.Errorf("title changed = %+v", ev)
+	}
+	if ev := evs[2]; ev.Area.ParentID != 9 || ev.OldArea.ParentID != 0 {
+		t.Errorf("area changed = %+v", ev)
+	}
+	if ev := evs[3]; ev.Reason != "违反直播规范" || ev.LiveKey != "223461829521467424" || ev.RoomID != 21852 {
+		t.Errorf("cut off = %+v", ev)
+	}
+	if ev := evs[5]; ev.LiveKey != "223461829521467500" || !ev.Time.Equal(now) || tr.Live() {
+		t.Errorf("ended = %+v", ev)
+	}
+}
+
+func TestSessionTrackerWithoutInit(t *testing.T) {
+	tr := NewSessionTracker(nil)
+	var evs []*SessionEvent
+	tr.OnEvent(func(ev *SessionEvent) { evs = append(evs, ev) })
+
+	// 不知道连接前是否在直播，PREPARING 不触发 StreamEnded
+	if err := tr.Handle(&MsgPreparing{base{raw: []byte(`{"cmd":"PREPARING","roomid":"21852"}`)}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 0 || tr.Live() {
+		t.Fatalf("events = %+v", evs)
+	}
+	for _, m := range []Msg{
+		&MsgLive{base{raw: []byte(`{"cmd":"LIVE","live_key":"k1","roomid":21852}`)}},
+		&MsgPreparing{base{raw: []byte(`{"cmd":"PREPARING","roomid":21852}`)}},
+	} {
+		if err := tr.Handle(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(evs) != 2 || evs[0].Type != StreamStarted || evs[1].Type != StreamEnded || evs[1].LiveKey != "k1" {
+		t.Errorf("events = %+v", evs)
+	}
+}
+
+func TestSessionTrackerCutOffFirst(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		init *RoomInfo
+		want string
+	}{
+		// 不知道连接前的状态，切断不触发事件
+		{"without init", nil, ""},
+		// Init 时已在直播，切断只触发一次，之后的 PREPARING 不再触发 StreamEnded
+		{"live at init", &RoomInfo{RoomID: 21852, LiveStatus: LiveStatusLive}, "cut_off"},
+	} {
+		tr := NewSessionTracker(nil)
+		if c.init != nil {
+			tr.Init(c.init)
+		}
+		var types []string
+		tr.OnEvent(func(ev *SessionEvent) { types = append(types, ev.Type.String()) })
+		for _, m := range []Msg{
+			cmdMsg(cmdCutOff, `{"cmd":"CUT_OFF","msg":"违反直播规范","roomid":21852}`),
+			cmdMsg(cmdPreparing, `{"cmd":"PREPARING","roomid":"21852"}`),
+		} {
+			if err := tr.Handle(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := strings.Join(types, ","); got != c.want || tr.Live() {
+			t.Errorf("%s: events = %q, Live() = %v", c.name, got, tr.Live())
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build go1.21
+
+package live
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger 将 *slog.Logger 包装为 Logger，l 为 nil 时使用 slog.Default。仅在 Go 1.21 及以上版本中提供
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &slogLogger{l: l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s *slogLogger) Debug(msg string, kv ...interface{}) { s.log(slog.LevelDebug, msg, kv) }
+func (s *slogLogger) Info(msg string, kv ...interface{})  { s.log(slog.LevelInfo, msg, kv) }
+func (s *slogLogger) Warn(msg string, kv ...interface{})  { s.log(slog.LevelWarn, msg, kv) }
+func (s *slogLogger) Error(msg string, kv ...interface{}) { s.log(slog.LevelError, msg, kv) }
+func (s *slogLogger) log(level slog.Level, msg string, kv []interface{}) {
+	s.l.Log(context.Background(), level, msg, kv...)
+}
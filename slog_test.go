@@ -0,0 +1,31 @@
+//go:build go1.21
+
+package live
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(WithLogger(NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.room, l.hosts, l.attempt = 21852, []string{"wss://a/sub"}, 2
+	l.error("websocket conn stopped with an error", "err", errors.New("eof"))
+
+	var r map[string]interface{}
+	if err = json.Unmarshal(buf.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]interface{}{"level": "ERROR", "msg": "websocket conn stopped with an error", "room": float64(21852), "host": "wss://a/sub", "attempt": float64(2), "err": "eof"} {
+		if r[k] != want {
+			t.Errorf("%s = %v, want %v", k, r[k], want)
+		}
+	}
+}
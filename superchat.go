@@ -0,0 +1,254 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type SuperChatEventType int
+
+const (
+	SuperChatAdded   SuperChatEventType = iota + 1 // 新的醒目留言
+	SuperChatUpdated                               // 收到翻译或重复的消息
+	SuperChatRemoved                               // 被删除或到期
+)
+
+func (t SuperChatEventType) String() string {
+	switch t {
+	case SuperChatAdded:
+		return "added"
+	case SuperChatUpdated:
+		return "updated"
+	case SuperChatRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// SuperChat 当前置顶的醒目留言
+type SuperChat struct {
+	ID         int64
+	UID        int64
+	Uname      string
+	Face       string
+	Price      int // 元
+	Message    string
+	MessageJPN string // SUPER_CHAT_MESSAGE_JPN 中的翻译
+	StartTime  int64  // unix 秒
+	EndTime    int64  // unix 秒，到期后移除
+}
+
+// SuperChatEvent 醒目留言的变化
+type SuperChatEvent struct {
+	Type      SuperChatEventType
+	SuperChat SuperChat
+	Expired   bool // 仅 SuperChatRemoved，true 为到期，false 为被删除
+}
+
+// SuperChatBoard 维护当前置顶的醒目留言，合并日文翻译，并在删除或到期时移除
+type SuperChatBoard struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	pinned  map[int64]*SuperChat
+	onEvent func(*SuperChatEvent)
+}
+
+// NewSuperChatBoard now 为时间来源，用于判断到期，nil 时使用 time.Now
+func NewSuperChatBoard(now func() time.Time) *SuperChatBoard {
+	if now == nil {
+		now = time.Now
+	}
+	return &SuperChatBoard{now: now, pinned: make(map[int64]*SuperChat)}
+}
+
+// OnEvent 醒目留言的添加、更新与移除
+func (b *SuperChatBoard) OnEvent(f func(*SuperChatEvent)) {
+	b.mu.Lock()
+	b.onEvent = f
+	b.mu.Unlock()
+}
+
+// Register 注册醒目留言及其删除
+func (b *SuperChatBoard) Register(d *Dispatcher) {
+	for _, cmd := range []string{cmdSuperChatMessage, cmdSuperChatMessageJPN, cmdSuperChatMessageDelete} {
+		d.on(cmd, b.Handle)
+	}
+}
+
+// Handle 处理一条消息，无关的消息会被忽略。每次处理前会先移除到期的醒目留言
+func (b *SuperChatBoard) Handle(m Msg) error {
+	var (
+		evs []*SuperChatEvent
+		err error
+	)
+	switch m := m.(type) {
+	case *MsgSuperChatMessage:
+		var sc *SuperChatMessage
+		if sc, err = m.Parse(); err == nil {
+			b.mu.Lock()
+			evs = append(b.expire(), b.put(&SuperChat{
+				ID: sc.ID, UID: sc.UID, Uname: sc.UserInfo.Uname, Face: sc.UserInfo.Face, Price: sc.Price,
+				Message: sc.Message, StartTime: sc.StartTime, EndTime: sc.EndTime,
+			}))
+			b.mu.Unlock()
+		}
+	case *MsgSuperChatMessageJPN:
+		var sc *SuperChatMessageJPN
+		if sc, err = m.Parse(); err == nil {
+			evs, err = b.jpn(sc)
+		}
+	case *MsgSuperChatMessageDelete:
+		var ids []int64
+		if ids, err = m.GetList(); err == nil {
+			b.mu.Lock()
+			evs = b.expire()
+			for _, id := range ids {
+				if sc, ok := b.pinned[id]; ok {
+					delete(b.pinned, id)
+					evs = append(evs, &SuperChatEvent{Type: SuperChatRemoved, SuperChat: *sc})
+				}
+			}
+			b.mu.Unlock()
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", m.Cmd(), err)
+	}
+	b.emit(evs)
+	return nil
+}
+
+func (b *SuperChatBoard) jpn(sc *SuperChatMessageJPN) ([]*SuperChatEvent, error) {
+	id, err := strconv.ParseInt(sc.ID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id %q: %s", sc.ID, err)
+	}
+	// uid 为字符串，缺失时不影响合并
+	uid, _ := strconv.ParseInt(sc.UID, 10, 64)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append(b.expire(), b.put(&SuperChat{
+		ID: id, UID: uid, Uname: sc.UserInfo.Uname, Face: sc.UserInfo.Face, Price: sc.Price,
+		Message: sc.Message, MessageJPN: sc.MessageJpn, StartTime: sc.StartTime, EndTime: sc.EndTime,
+	})), nil
+}
+
+// put 添加或合并一条醒目留言，已到期的直接忽略
+func (b *SuperChatBoard) put(sc *SuperChat) *SuperChatEvent {
+	if sc.EndTime != 0 && !b.now().Before(time.Unix(sc.EndTime, 0)) {
+		return nil
+	}
+	old, ok := b.pinned[sc.ID]
+	if !ok {
+		b.pinned[sc.ID] = sc
+		return &SuperChatEvent{Type: SuperChatAdded, SuperChat: *sc}
+	}
+	merged := *old
+	if sc.MessageJPN != "" {
+		merged.MessageJPN = sc.MessageJPN
+	}
+	if sc.Message != "" {
+		merged.Message = sc.Message
+	}
+	if sc.Uname != "" {
+		merged.Uname, merged.Face = sc.Uname, sc.Face
+	}
+	if sc.UID != 0 {
+		merged.UID = sc.UID
+	}
+	if sc.Price != 0 {
+		merged.Price = sc.Price
+	}
+	if sc.EndTime != 0 {
+		merged.StartTime, merged.EndTime = sc.StartTime, sc.EndTime
+	}
+	if merged == *old {
+		return nil
+	}
+	b.pinned[sc.ID] = &merged
+	return &SuperChatEvent{Type: SuperChatUpdated, SuperChat: merged}
+}
+
+// expire 移除到期的醒目留言
+func (b *SuperChatBoard) expire() []*SuperChatEvent {
+	now := b.now()
+	var evs []*SuperChatEvent
+	for _, sc := range b.sorted() {
+		if sc.EndTime != 0 && !now.Before(time.Unix(sc.EndTime, 0)) {
+			delete(b.pinned, sc.ID)
+			evs = append(evs, &SuperChatEvent{Type: SuperChatRemoved, SuperChat: *sc, Expired: true})
+		}
+	}
+	return evs
+}
+
+func (b *SuperChatBoard) emit(evs []*SuperChatEvent) {
+	b.mu.Lock()
+	f := b.onEvent
+	b.mu.Unlock()
+	if f == nil {
+		return
+	}
+	for _, ev := range evs {
+		if ev != nil {
+			f(ev)
+		}
+	}
+}
+
+// Expire 移除到期的醒目留言。没有新消息时需要定期调用，或使用 Run
+func (b *SuperChatBoard) Expire() {
+	b.mu.Lock()
+	evs := b.expire()
+	b.mu.Unlock()
+	b.emit(evs)
+}
+
+// Run 每隔 interval 调用一次 Expire，直到 ctx 结束。interval <= 0 时为 1s
+func (b *SuperChatBoard) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			b.Expire()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// List 当前置顶的醒目留言，按开始时间排序
+func (b *SuperChatBoard) List() []SuperChat {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var r []SuperChat
+	for _, sc := range b.sorted() {
+		r = append(r, *sc)
+	}
+	return r
+}
+
+func (b *SuperChatBoard) sorted() []*SuperChat {
+	r := make([]*SuperChat, 0, len(b.pinned))
+	for _, sc := range b.pinned {
+		r = append(r, sc)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].StartTime != r[j].StartTime {
+			return r[i].StartTime < r[j].StartTime
+		}
+		return r[i].ID < r[j].ID
+	})
+	return r
+}
@@ -0,0 +1,93 @@
+package live
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSuperChatBoard(t *testing.T) {
+	now := time.Unix(1651400000, 0)
+	b := NewSuperChatBoard(func() time.Time { return now })
+	var evs []string
+	b.OnEvent(func(ev *SuperChatEvent) {
+		s := ev.Type.String()
+		if ev.Expired {
+			s += "(expired)"
+		}
+		evs = append(evs, s)
+	})
+	d := newTrackerDispatcher(t, b)
+
+	d.DispatchMsg(cmdMsg(cmdSuperChatMessage, `{"cmd":"SUPER_CHAT_MESSAGE","data":{"id":1,"uid":2920960,"price":30,"message":"主播好","start_time":1651400000,"end_time":1651400060,"user_info":{"uname":"一只鱼"}}}`))
+	d.DispatchMsg(cmdMsg(cmdSuperChatMessageJPN, `{"cmd":"SUPER_CHAT_MESSAGE_JPN","data":{"id":"1","uid":"2920960","price":30,"message":"主播好","message_jpn":"こんにちは","start_time":1651400000,"end_time":1651400060,"user_info":{"uname":"一只鱼"}}}`))
+	// 重复的翻译不产生事件
+	d.DispatchMsg(cmdMsg(cmdSuperChatMessageJPN, `{"cmd":"SUPER_CHAT_MESSAGE_JPN","data":{"id":"1","uid":"2920960","price":30,"message":"主播好","message_jpn":"こんにちは","start_time":1651400000,"end_time":1651400060,"user_info":{"uname":"一只鱼"}}}`))
+	d.DispatchMsg(cmdMsg(cmdSuperChatMessage, `{"cmd":"SUPER_CHAT_MESSAGE","data":{"id":2,"uid":1001,"price":100,"message":"加油","start_time":1651400010,"end_time":1651400130,"user_info":{"uname":"路人"}}}`))
+	d.DispatchMsg(cmdMsg(cmdSuperChatMessage, `{"cmd":"SUPER_CHAT_MESSAGE","data":{"id":3,"uid":1002,"price":50,"message":"已过期","start_time":1651399000,"end_time":1651399060}}`))
+
+	list := b.List()
+	if len(list) != 2 || list[0].ID != 1 || list[0].MessageJPN != "こんにちは" || list[0].Uname != "一只鱼" || list[1].ID != 2 {
+		t.Fatalf("List() = %+v", list)
+	}
+
+	now = now.Add(time.Minute)
+	b.Expire()
+	d.DispatchMsg(cmdMsg(cmdSuperChatMessageDelete, `{"cmd":"SUPER_CHAT_MESSAGE_DELETE","data":{"ids":[2,404]}}`))
+	if len(b.List()) != 0 {
+		t.Errorf("List() = %+v, want empty", b.List())
+	}
+
+	want := []string{"added", "updated", "added", "removed(expired)", "removed"}
+	if len(evs) != len(want) {
+		t.Fatalf("events = %v, want %v", evs, want)
+	}
+	for i := range want {
+		if evs[i] != want[i] {
+			t.Errorf("events = %v, want %v", evs, want)
+			break
+		}
+	}
+}
+
+func TestSuperChatBoardRun(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		now = time.Unix(1651400000, 0)
+	)
+	b := NewSuperChatBoard(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+	removed := make(chan *SuperChatEvent, 1)
+	b.OnEvent(func(ev *SuperChatEvent) {
+		if ev.Type == SuperChatRemoved {
+			removed <- ev
+		}
+	})
+	if err := b.Handle(cmdMsg(cmdSuperChatMessage, `{"cmd":"SUPER_CHAT_MESSAGE","data":{"id":1,"uid":2920960,"price":30,"message":"主播好","start_time":1651400000,"end_time":1651400060}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx, 10*time.Millisecond)
+	// 没有新消息时也会按时移除到期的醒目留言
+	mu.Lock()
+	now = now.Add(time.Minute)
+	mu.Unlock()
+	select {
+	case ev := <-removed:
+		if ev.SuperChat.ID != 1 || !ev.Expired {
+			t.Errorf("event = %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("super chat not expired by Run")
+	}
+
+	// interval <= 0 时使用默认值，不会 panic
+	cancel()
+	b.Run(ctx, 0)
+}
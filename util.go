@@ -51,6 +51,18 @@ func zlibDe(src []byte) ([]byte, error) {
 	}
 	return o.Bytes(), nil
 }
+func zlibEn(src []byte) ([]byte, error) {
+	b := new(bytes.Buffer)
+	w := zlib.NewWriter(b)
+
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
 func brotliDe(src []byte) ([]byte, error) {
 	o := new(bytes.Buffer)
 	r := brotli.NewReader(bytes.NewReader(src))
@@ -43,3 +43,16 @@ func TestBrotliDecode(t *testing.T) {
 		t.FailNow()
 	}
 }
+func TestZlibDecode(t *testing.T) {
+	b, err := zlibEn([]byte("aaaaadsadadsa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = zlibDe(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "aaaaadsadadsa" {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,219 @@
+package live
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Viewer 观众的进入与互动记录
+type Viewer struct {
+	UID        int64
+	Uname      string
+	FirstSeen  time.Time
+	LastSeen   time.Time
+	Enters     int // 进入直播间次数，包括 ENTRY_EFFECT
+	Follows    int // 关注，包括特别关注与互相关注
+	Shares     int
+	GuardLevel int // 大航海等级，参见 GuardLevelCaptain
+	MedalName  string
+	MedalLevel int // 本直播间的粉丝勋章等级，0 为没有
+}
+
+// ViewerEvent 一次进入、关注或分享
+type ViewerEvent struct {
+	MsgType        int    // 参见 InteractEnter，ENTRY_EFFECT 为 InteractEnter
+	Effect         bool   // 来自 ENTRY_EFFECT
+	Viewer         Viewer // 记录的副本
+	FirstInSession bool   // 本场第一次进入
+	Returning      bool   // 之前的场次进入过的粉丝勋章持有者
+}
+
+type viewerEntry struct {
+	v       Viewer
+	session int // 最近一次进入的场次
+}
+
+// ViewerTracker 根据 INTERACT_WORD 和 ENTRY_EFFECT 记录观众的首次与最近出现时间，统计每场的进入人数。
+// 记录数超过上限时淘汰最久未出现的观众，被淘汰的观众再次出现时重新开始记录。
+// 每场的进入人数另外按 uid 去重，不受上限影响
+type ViewerTracker struct {
+	mu       sync.Mutex
+	room     int64
+	size     int
+	now      func() time.Time
+	lru      *list.List // 最近出现的在前
+	entries  map[int64]*list.Element
+	session  int
+	entered  map[int64]bool // 本场进入过的 uid
+	onViewer func(*ViewerEvent)
+}
+
+// NewViewerTracker room 为本直播间的真实ID，用于判断粉丝勋章是否属于本直播间，为 0 时不判断。
+// size 为最多记录的观众数，<=0 时为 100000。now 为时间来源，nil 时使用 time.Now
+func NewViewerTracker(room int64, size int, now func() time.Time) *ViewerTracker {
+	if size <= 0 {
+		size = 100000
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &ViewerTracker{
+		room:    room,
+		size:    size,
+		now:     now,
+		lru:     list.New(),
+		entries: make(map[int64]*list.Element),
+		session: 1,
+		entered: make(map[int64]bool),
+	}
+}
+
+// OnViewer 观众进入、关注或分享
+func (t *ViewerTracker) OnViewer(f func(*ViewerEvent)) {
+	t.mu.Lock()
+	t.onViewer = f
+	t.mu.Unlock()
+}
+
+// NewSession 开始新的一场，例如收到 StreamStarted 时。之前的记录会保留，用于判断回访
+func (t *ViewerTracker) NewSession() {
+	t.mu.Lock()
+	t.session++
+	t.entered = make(map[int64]bool)
+	t.mu.Unlock()
+}
+
+// Entrants 本场进入直播间的不重复人数
+func (t *ViewerTracker) Entrants() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.entered)
+}
+
+// Len 当前记录的观众数
+func (t *ViewerTracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lru.Len()
+}
+
+// Viewer uid 的记录
+func (t *ViewerTracker) Viewer(uid int64) (Viewer, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.entries[uid]
+	if !ok {
+		return Viewer{}, false
+	}
+	return e.Value.(*viewerEntry).v, true
+}
+
+// Register 注册 INTERACT_WORD 与 ENTRY_EFFECT
+func (t *ViewerTracker) Register(d *Dispatcher) {
+	for _, cmd := range []string{cmdInteractWord, cmdEntryEffect} {
+		d.on(cmd, t.Handle)
+	}
+}
+
+// Handle 处理一条消息，无关的消息会被忽略
+func (t *ViewerTracker) Handle(m Msg) error {
+	var (
+		ev  *ViewerEvent
+		err error
+	)
+	switch m := m.(type) {
+	case *MsgInteractWord:
+		var w *InteractWord
+		if w, err = m.Parse(); err == nil {
+			medal := 0
+			if t.room == 0 || w.FansMedal.AnchorRoomID == t.room {
+				medal = w.FansMedal.MedalLevel
+			}
+			ev = t.record(w.UID, w.MsgType, false, func(v *Viewer) {
+				v.Uname = w.Uname
+				if medal > 0 {
+					v.MedalName, v.MedalLevel = w.FansMedal.MedalName, medal
+					v.GuardLevel = w.FansMedal.GuardLevel
+				}
+			})
+		}
+	case *MsgEntryEffect:
+		var e *EntryEffect
+		if e, err = m.Parse(); err == nil {
+			ev = t.record(e.UID, InteractEnter, true, func(v *Viewer) {
+				if n := e.Uname(); n != "" {
+					v.Uname = n
+				}
+				if e.PrivilegeType != 0 {
+					v.GuardLevel = e.PrivilegeType
+				}
+			})
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", m.Cmd(), err)
+	}
+	if ev == nil {
+		return nil
+	}
+	t.mu.Lock()
+	f := t.onViewer
+	t.mu.Unlock()
+	if f != nil {
+		f(ev)
+	}
+	return nil
+}
+
+func (t *ViewerTracker) record(uid int64, typ int, effect bool, set func(*Viewer)) *ViewerEvent {
+	if uid == 0 {
+		// 未登录用户的 uid 为 0，无法区分
+		return nil
+	}
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var e *viewerEntry
+	if el, ok := t.entries[uid]; ok {
+		t.lru.MoveToFront(el)
+		e = el.Value.(*viewerEntry)
+	} else {
+		e = &viewerEntry{v: Viewer{UID: uid, FirstSeen: now}}
+		t.entries[uid] = t.lru.PushFront(e)
+		t.evict()
+	}
+	e.v.LastSeen = now
+	set(&e.v)
+
+	ev := &ViewerEvent{MsgType: typ, Effect: effect}
+	switch typ {
+	case InteractEnter:
+		e.v.Enters++
+		if !t.entered[uid] {
+			ev.FirstInSession = true
+			ev.Returning = e.session != 0 && e.session != t.session && e.v.MedalLevel > 0
+			t.entered[uid] = true
+		}
+		e.session = t.session
+	case InteractFollow, InteractSpecialFollow, InteractMutualFollow:
+		e.v.Follows++
+	case InteractShare:
+		e.v.Shares++
+	}
+	ev.Viewer = e.v
+	return ev
+}
+
+// evict 淘汰超过上限的记录
+func (t *ViewerTracker) evict() {
+	for t.lru.Len() > t.size {
+		el := t.lru.Back()
+		t.lru.Remove(el)
+		delete(t.entries, el.Value.(*viewerEntry).v.UID)
+	}
+}
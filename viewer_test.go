@@ -0,0 +1,84 @@
+package live
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestViewerTracker(t *testing.T) {
+	interact := func(uid int64, typ, medal int) Msg {
+		return cmdMsg(cmdInteractWord, fmt.Sprintf(`{"cmd":"INTERACT_WORD","data":{"uid":%d,"uname":"u%d","msg_type":%d,"roomid":21852,"fans_medal":{"medal_level":%d,"medal_name":"鱼粉","anchor_roomid":21852,"guard_level":0}}}`, uid, uid, typ, medal))
+	}
+
+	now := time.Unix(1651400000, 0)
+	tr := NewViewerTracker(21852, 3, func() time.Time { return now })
+	var evs []*ViewerEvent
+	tr.OnViewer(func(ev *ViewerEvent) { evs = append(evs, ev) })
+	d := newTrackerDispatcher(t, tr)
+
+	d.DispatchMsg(interact(1, InteractEnter, 21))
+	now = now.Add(time.Minute)
+	d.DispatchMsg(interact(1, InteractEnter, 21))
+	d.DispatchMsg(interact(1, InteractFollow, 21))
+	d.DispatchMsg(interact(2, InteractShare, 0))
+	d.DispatchMsg(cmdMsg(cmdEntryEffect, `{"cmd":"ENTRY_EFFECT","data":{"id":4,"uid":3,"target_id":546195,"privilege_type":3,"copy_writing":"欢迎舰长 <%u3%> 进入直播间","trigger_time":1651400060000000000}}`))
+	d.DispatchMsg(interact(0, InteractEnter, 0)) // 未登录用户
+
+	if tr.Entrants() != 2 {
+		t.Errorf("Entrants() = %d, want 2", tr.Entrants())
+	}
+	v, ok := tr.Viewer(1)
+	if !ok || v.Enters != 2 || v.Follows != 1 || v.MedalLevel != 21 || v.FirstSeen.Unix() != 1651400000 || v.LastSeen.Unix() != 1651400060 {
+		t.Errorf("Viewer(1) = %+v", v)
+	}
+	if v, _ = tr.Viewer(3); v.Uname != "u3" || v.GuardLevel != GuardLevelCaptain || v.Enters != 1 {
+		t.Errorf("Viewer(3) = %+v", v)
+	}
+	if len(evs) != 5 || !evs[0].FirstInSession || evs[1].FirstInSession || evs[2].MsgType != InteractFollow || !evs[4].Effect {
+		t.Errorf("events = %+v", evs)
+	}
+
+	// 新的一场，持有勋章的回访观众会被标记
+	tr.NewSession()
+	d.DispatchMsg(interact(1, InteractEnter, 21))
+	d.DispatchMsg(interact(3, InteractEnter, 0))
+	if ev := evs[5]; !ev.FirstInSession || !ev.Returning {
+		t.Errorf("returning medal holder = %+v", ev)
+	}
+	if ev := evs[6]; !ev.FirstInSession || ev.Returning {
+		t.Errorf("returning viewer without medal = %+v", ev)
+	}
+
+	// 超过上限时淘汰最久未出现的观众
+	d.DispatchMsg(interact(4, InteractEnter, 0))
+	if _, ok := tr.Viewer(2); ok || tr.Len() != 3 {
+		t.Errorf("Viewer(2) should be evicted, Len() = %d", tr.Len())
+	}
+	if tr.Entrants() != 3 {
+		t.Errorf("Entrants() = %d, want 3", tr.Entrants())
+	}
+}
+
+func TestViewerTrackerEvictedReenter(t *testing.T) {
+	tr := NewViewerTracker(0, 2, nil)
+	var first int
+	tr.OnViewer(func(ev *ViewerEvent) {
+		if ev.FirstInSession {
+			first++
+		}
+	})
+	// uid 1 被淘汰后再次进入，仍是本场的同一个观众
+	for _, uid := range []int64{1, 2, 3, 1} {
+		m := cmdMsg(cmdInteractWord, fmt.Sprintf(`{"cmd":"INTERACT_WORD","data":{"uid":%d,"uname":"u%d","msg_type":1}}`, uid, uid))
+		if err := tr.Handle(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tr.Entrants() != 3 || first != 3 || tr.Len() != 2 {
+		t.Errorf("Entrants() = %d, first = %d, Len() = %d", tr.Entrants(), first, tr.Len())
+	}
+	if v, ok := tr.Viewer(1); !ok || v.Enters != 1 {
+		t.Errorf("Viewer(1) = %+v, %v", v, ok)
+	}
+}